@@ -0,0 +1,170 @@
+package rest
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Authenticator adds credentials to an outgoing HTTP request. It is called
+// for every attempt, including retries.
+type Authenticator interface {
+	Authenticate(ctx context.Context, req *http.Request) error
+}
+
+// Invalidator is implemented by Authenticators that can renew their
+// credentials. When a request is rejected with a 401, the Client calls
+// Invalidate with that request and resends it once.
+type Invalidator interface {
+	Invalidate(req *http.Request)
+}
+
+// AuthenticatorFunc adapts a function to the Authenticator interface.
+type AuthenticatorFunc func(ctx context.Context, req *http.Request) error
+
+// Authenticate calls f(ctx, req).
+func (f AuthenticatorFunc) Authenticate(ctx context.Context, req *http.Request) error {
+	return f(ctx, req)
+}
+
+// BearerToken returns an Authenticator that sends a static bearer token.
+func BearerToken(token string) Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context, req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// BasicAuth returns an Authenticator that sends HTTP basic credentials.
+func BasicAuth(username, password string) Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context, req *http.Request) error {
+		req.SetBasicAuth(username, password)
+		return nil
+	})
+}
+
+// basicCredentials encodes a username and password for HTTP basic auth.
+func basicCredentials(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+}
+
+// APIKeyHeader returns an Authenticator that sends key in the given header.
+func APIKeyHeader(header, key string) Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context, req *http.Request) error {
+		req.Header.Set(header, key)
+		return nil
+	})
+}
+
+// APIKeyQuery returns an Authenticator that sends key as the given query
+// parameter.
+func APIKeyQuery(param, key string) Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context, req *http.Request) error {
+		query, err := mergeQuery(req.URL.RawQuery, Query{{Key: param, Value: key}}, QueryReplace)
+		if err != nil {
+			return err
+		}
+		req.URL.RawQuery = query
+		return nil
+	})
+}
+
+// Token is an access token and the time it expires.
+type Token struct {
+	AccessToken string
+	Expiry      time.Time // zero if the token does not expire
+}
+
+// TokenSource fetches a new Token.
+type TokenSource func(ctx context.Context) (*Token, error)
+
+// tokenExpiryDelta is how long before its expiry a token is renewed, so it
+// does not expire in flight.
+const tokenExpiryDelta = 10 * time.Second
+
+// RefreshableToken is an Authenticator that sends a bearer token fetched
+// from Source. The token is cached until shortly before it expires, or until
+// a request using it is rejected with a 401. Concurrent requests share a
+// single fetch.
+type RefreshableToken struct {
+	Source TokenSource
+
+	mu    sync.Mutex
+	token *Token
+	fetch *tokenFetch // in progress, if any
+}
+
+// tokenFetch is a call to Source that concurrent callers wait on.
+type tokenFetch struct {
+	done     chan struct{}
+	token    *Token
+	err      error
+	canceled bool // the context of the caller that started it is done
+}
+
+// Authenticate sets the Authorization header to the current token,
+// fetching a new one if needed.
+func (t *RefreshableToken) Authenticate(ctx context.Context, req *http.Request) error {
+	token, err := t.Token(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	return nil
+}
+
+// Token returns the cached token, fetching a new one if there is none or
+// it is about to expire. Callers that wait on another caller's fetch
+// return early when their own ctx is done.
+func (t *RefreshableToken) Token(ctx context.Context) (*Token, error) {
+	for {
+		t.mu.Lock()
+		if t.token != nil && (t.token.Expiry.IsZero() || time.Until(t.token.Expiry) > tokenExpiryDelta) {
+			token := t.token
+			t.mu.Unlock()
+			return token, nil
+		}
+		if f := t.fetch; f != nil {
+			t.mu.Unlock()
+			select {
+			case <-f.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			// A fetch abandoned by its caller says nothing about ours.
+			if f.canceled && ctx.Err() == nil {
+				continue
+			}
+			return f.token, f.err
+		}
+		f := &tokenFetch{done: make(chan struct{})}
+		t.fetch = f
+		t.mu.Unlock()
+
+		f.token, f.err = t.Source(ctx)
+		if f.err != nil {
+			f.token = nil
+		}
+		f.canceled = ctx.Err() != nil
+		t.mu.Lock()
+		if f.err == nil {
+			t.token = f.token
+		}
+		t.fetch = nil
+		t.mu.Unlock()
+		close(f.done)
+		return f.token, f.err
+	}
+}
+
+// Invalidate drops the cached token if it is the one req was sent with.
+// Requests rejected with an older token do not cause another fetch.
+func (t *RefreshableToken) Invalidate(req *http.Request) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != nil && req.Header.Get("Authorization") == "Bearer "+t.token.AccessToken {
+		t.token = nil
+	}
+}
@@ -0,0 +1,191 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestAuthenticators(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		auth  Authenticator
+		check func(r *http.Request) bool
+	}{
+		{BearerToken("API_KEY"), func(r *http.Request) bool {
+			return r.Header.Get("Authorization") == "Bearer API_KEY"
+		}},
+		{BasicAuth("user", "pass"), func(r *http.Request) bool {
+			user, pass, ok := r.BasicAuth()
+			return ok && user == "user" && pass == "pass"
+		}},
+		{APIKeyHeader("X-Api-Key", "API_KEY"), func(r *http.Request) bool {
+			return r.Header.Get("X-Api-Key") == "API_KEY"
+		}},
+		{APIKeyQuery("api_key", "API_KEY"), func(r *http.Request) bool {
+			return r.URL.RawQuery == "flag&limit=10&api_key=API_KEY"
+		}},
+	}
+	for i, test := range tests {
+		check := test.check
+		fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !check(r) {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}))
+		client := &Client{HTTPClient: &http.Client{}, Authenticator: test.auth}
+		request := Request{Method: Get, BaseURL: fakeServer.URL + "?flag", QueryParams: map[string]string{"limit": "10"}}
+		response, err := client.Send(request)
+		if err != nil || response.StatusCode != 200 {
+			t.Errorf("Authenticator %d was not applied: %v, %v", i, response, err)
+		}
+		if len(request.Headers) != 0 {
+			t.Errorf("Authenticator %d leaked credentials into the Request", i)
+		}
+		fakeServer.Close()
+	}
+}
+
+func TestRefreshableTokenOn401(t *testing.T) {
+	t.Parallel()
+	var fetches int32
+	source := func(ctx context.Context) (*Token, error) {
+		n := atomic.AddInt32(&fetches, 1)
+		return &Token{AccessToken: fmt.Sprintf("token-%d", n), Expiry: time.Now().Add(time.Hour)}, nil
+	}
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer fakeServer.Close()
+
+	auth := &RefreshableToken{Source: source}
+	client := &Client{HTTPClient: &http.Client{}, Authenticator: auth}
+	response, err := client.Send(Request{Method: Post, BaseURL: fakeServer.URL, Body: []byte("{}")})
+	if err != nil || response.StatusCode != 200 {
+		t.Fatalf("Expected the request to be resent with a new token: %v, %v", response, err)
+	}
+	if fetches != 2 {
+		t.Errorf("Expected 2 token fetches, got %d", fetches)
+	}
+}
+
+func TestRefreshableTokenStaysRejected(t *testing.T) {
+	t.Parallel()
+	var requests int32
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer fakeServer.Close()
+
+	auth := &RefreshableToken{Source: func(ctx context.Context) (*Token, error) {
+		return &Token{AccessToken: "bad"}, nil
+	}}
+	client := &Client{HTTPClient: &http.Client{}, Authenticator: auth, RetryPolicy: DefaultRetryPolicy()}
+	response, err := client.Send(Request{Method: Get, BaseURL: fakeServer.URL})
+	if err != nil || response.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected a 401 response, got %v, %v", response, err)
+	}
+	if requests != 2 {
+		t.Errorf("A 401 should be resent exactly once, got %d requests", requests)
+	}
+}
+
+func TestRefreshableTokenCache(t *testing.T) {
+	t.Parallel()
+	var fetches int32
+	auth := &RefreshableToken{Source: func(ctx context.Context) (*Token, error) {
+		atomic.AddInt32(&fetches, 1)
+		time.Sleep(10 * time.Millisecond)
+		return &Token{AccessToken: "token", Expiry: time.Now().Add(time.Hour)}, nil
+	}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest("GET", "http://api.test.com", nil)
+			if err := auth.Authenticate(context.Background(), req); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if fetches != 1 {
+		t.Errorf("Concurrent requests should share one fetch, got %d", fetches)
+	}
+
+	stale, _ := http.NewRequest("GET", "http://api.test.com", nil)
+	stale.Header.Set("Authorization", "Bearer old")
+	auth.Invalidate(stale)
+	if _, err := auth.Token(context.Background()); err != nil || fetches != 1 {
+		t.Error("A 401 for an older token should not cause a fetch")
+	}
+
+	auth.token.Expiry = time.Now().Add(time.Second)
+	if _, err := auth.Token(context.Background()); err != nil || fetches != 2 {
+		t.Error("A token about to expire should be renewed")
+	}
+}
+
+func TestRefreshableTokenContext(t *testing.T) {
+	t.Parallel()
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	auth := &RefreshableToken{Source: func(ctx context.Context) (*Token, error) {
+		started <- struct{}{}
+		select {
+		case <-release:
+			return &Token{AccessToken: "token"}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}}
+
+	first, cancelFirst := context.WithCancel(context.Background())
+	firstErr := make(chan error)
+	go func() {
+		_, err := auth.Token(first)
+		firstErr <- err
+	}()
+	<-started
+
+	// A caller waiting on the fetch gives up when its own context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := auth.Token(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Waiting for the token did not respect the caller's context")
+	}
+
+	// A caller whose fetch was abandoned by another caller fetches again.
+	token := make(chan *Token)
+	go func() {
+		tok, err := auth.Token(context.Background())
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		token <- tok
+	}()
+	cancelFirst()
+	if err := <-firstErr; err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	<-started
+	close(release)
+	if tok := <-token; tok == nil || tok.AccessToken != "token" {
+		t.Errorf("Invalid token: %v", tok)
+	}
+}
@@ -0,0 +1,154 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// Errors returned when a Bulkhead has no free slot for a request.
+var (
+	ErrQueueFull    = errors.New("rest: too many requests waiting for host")
+	ErrQueueTimeout = errors.New("rest: timed out waiting for host")
+)
+
+// Bulkhead limits the number of requests in flight to each host, so one
+// slow dependency cannot use up every connection. Requests over the limit
+// wait in a bounded queue, in order, for a request to the same host to
+// finish. A request stays in flight until its response body is closed.
+//
+// It is safe for concurrent use.
+type Bulkhead struct {
+	// MaxInFlight is the most requests in flight to a host. Zero means
+	// no limit.
+	MaxInFlight int
+	// MaxQueue is the most requests waiting for a host. Requests beyond
+	// it fail with ErrQueueFull. Zero means requests never wait.
+	MaxQueue int
+	// QueueTimeout is the longest a request waits in the queue before
+	// failing with ErrQueueTimeout. Zero means it waits until its context
+	// is done.
+	QueueTimeout time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*bulkheadHost
+}
+
+// BulkheadStats is a snapshot of the requests to a host.
+type BulkheadStats struct {
+	InFlight int // requests sent and not yet finished
+	Queued   int // requests waiting to be sent
+}
+
+type bulkheadHost struct {
+	inFlight int
+	queue    []chan struct{}
+}
+
+// Stats returns the number of requests in flight and queued for each host
+// that has had requests.
+func (b *Bulkhead) Stats() map[string]BulkheadStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := make(map[string]BulkheadStats, len(b.hosts))
+	for host, h := range b.hosts {
+		stats[host] = BulkheadStats{InFlight: h.inFlight, Queued: len(h.queue)}
+	}
+	return stats
+}
+
+// acquire waits for a slot for a request to host. The caller must call
+// release once the request is finished.
+func (b *Bulkhead) acquire(ctx context.Context, host string) error {
+	if b.MaxInFlight <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	if b.hosts == nil {
+		b.hosts = make(map[string]*bulkheadHost)
+	}
+	h := b.hosts[host]
+	if h == nil {
+		h = &bulkheadHost{}
+		b.hosts[host] = h
+	}
+	if h.inFlight < b.MaxInFlight {
+		h.inFlight++
+		b.mu.Unlock()
+		return nil
+	}
+	if len(h.queue) >= b.MaxQueue {
+		b.mu.Unlock()
+		return ErrQueueFull
+	}
+	ready := make(chan struct{})
+	h.queue = append(h.queue, ready)
+	b.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if b.QueueTimeout > 0 {
+		timer := time.NewTimer(b.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var err error
+	select {
+	case <-ready:
+		return nil
+	case <-timeout:
+		err = ErrQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, waiter := range h.queue {
+		if waiter == ready {
+			h.queue = append(h.queue[:i], h.queue[i+1:]...)
+			return err
+		}
+	}
+	// The slot was handed over as we gave up, so pass it on.
+	b.releaseLocked(h)
+	return err
+}
+
+// release frees the slot of a finished request to host.
+func (b *Bulkhead) release(host string) {
+	if b.MaxInFlight <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if h := b.hosts[host]; h != nil {
+		b.releaseLocked(h)
+	}
+}
+
+// releaseLocked hands a slot to the first queued request, if any.
+func (b *Bulkhead) releaseLocked(h *bulkheadHost) {
+	if len(h.queue) > 0 {
+		close(h.queue[0])
+		h.queue = h.queue[1:]
+		return
+	}
+	if h.inFlight > 0 {
+		h.inFlight--
+	}
+}
+
+// releaseBody releases a Bulkhead slot when the response body is closed.
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseBody) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
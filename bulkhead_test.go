@@ -0,0 +1,157 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestBulkhead(t *testing.T) {
+	t.Parallel()
+	var inFlight, maxInFlight int32
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+	}))
+	defer fakeServer.Close()
+
+	client := &Client{HTTPClient: &http.Client{}, Bulkhead: &Bulkhead{MaxInFlight: 2, MaxQueue: 10}}
+	var wg sync.WaitGroup
+	var failures int32
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Send(Request{Method: Get, BaseURL: fakeServer.URL}); err != nil {
+				atomic.AddInt32(&failures, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&failures); n != 0 {
+		t.Errorf("%d requests failed", n)
+	}
+	if n := atomic.LoadInt32(&maxInFlight); n > 2 {
+		t.Errorf("Expected at most 2 requests in flight, got %d", n)
+	}
+	stats := client.Bulkhead.Stats()[fakeServer.Listener.Addr().String()]
+	if stats.InFlight != 0 || stats.Queued != 0 {
+		t.Errorf("Slots were not released: %+v", stats)
+	}
+}
+
+func TestBulkheadQueue(t *testing.T) {
+	t.Parallel()
+	bulkhead := &Bulkhead{MaxInFlight: 1, MaxQueue: 1, QueueTimeout: 20 * time.Millisecond}
+	ctx := context.Background()
+	if err := bulkhead.acquire(ctx, "example.com"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	queued := make(chan error)
+	go func() {
+		queued <- bulkhead.acquire(ctx, "example.com")
+	}()
+	for bulkhead.Stats()["example.com"].Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := bulkhead.acquire(ctx, "example.com"); err != ErrQueueFull {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+	if err := <-queued; err != ErrQueueTimeout {
+		t.Errorf("Expected ErrQueueTimeout, got %v", err)
+	}
+
+	// Other hosts are not affected.
+	if err := bulkhead.acquire(ctx, "other.com"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	go func() {
+		queued <- bulkhead.acquire(ctx, "example.com")
+	}()
+	for bulkhead.Stats()["example.com"].Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	bulkhead.release("example.com")
+	if err := <-queued; err != nil {
+		t.Errorf("Queued request did not get the released slot: %v", err)
+	}
+	stats := bulkhead.Stats()["example.com"]
+	if stats.InFlight != 1 || stats.Queued != 0 {
+		t.Errorf("Wrong stats: %+v", stats)
+	}
+}
+
+func TestBulkheadCancel(t *testing.T) {
+	t.Parallel()
+	bulkhead := &Bulkhead{MaxInFlight: 1, MaxQueue: 1}
+	if err := bulkhead.acquire(context.Background(), "example.com"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := bulkhead.acquire(ctx, "example.com"); err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if stats := bulkhead.Stats()["example.com"]; stats.Queued != 0 {
+		t.Errorf("Cancelled request is still queued: %+v", stats)
+	}
+}
+
+func TestBulkheadRateLimiter(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer fakeServer.Close()
+	host := fakeServer.Listener.Addr().String()
+
+	client := &Client{
+		HTTPClient:  &http.Client{},
+		Bulkhead:    &Bulkhead{MaxInFlight: 1},
+		RateLimiter: &RateLimiter{Default: Limit{Rate: 0.01, Burst: 2}},
+	}
+	request := Request{Method: Get, BaseURL: fakeServer.URL}
+
+	// Requests rejected by the bulkhead do not take rate limiter tokens.
+	if err := client.Bulkhead.acquire(context.Background(), host); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := client.Send(request); err != ErrQueueFull {
+			t.Errorf("Expected ErrQueueFull, got %v", err)
+		}
+	}
+	client.Bulkhead.release(host)
+
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, err := client.SendWithContext(ctx, request)
+		cancel()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	// A request that gives up waiting for a token releases its slot.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := client.SendWithContext(ctx, request); err == nil {
+		t.Error("Expected the rate limiter to fail the request")
+	}
+	if stats := client.Bulkhead.Stats()[host]; stats.InFlight != 0 {
+		t.Errorf("Slot was not released: %+v", stats)
+	}
+}
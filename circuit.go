@@ -0,0 +1,313 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned, without sending the request, while the
+// circuit for a request's host is open.
+var ErrCircuitOpen = errors.New("rest: circuit breaker is open")
+
+// CircuitState is the state of a circuit.
+type CircuitState int
+
+// Circuit states.
+const (
+	// CircuitClosed lets requests through and counts their failures.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails requests with ErrCircuitOpen.
+	CircuitOpen
+	// CircuitHalfOpen lets a few probe requests through to decide whether
+	// to close the circuit again.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// circuitBuckets is the number of buckets the rolling window is split into.
+const circuitBuckets = 10
+
+// CircuitBreaker fails requests fast once too many recent requests to the
+// same host have failed. Each host has its own circuit, which opens when
+// the failure rate over the rolling Window reaches FailureRate. After
+// OpenTimeout it lets HalfOpenRequests probes through; if they all succeed
+// the circuit closes, and if any fails it opens again.
+//
+// It is safe for concurrent use and the zero value is ready to use.
+type CircuitBreaker struct {
+	// FailureRate is the fraction of failed requests, between 0 and 1,
+	// that opens a circuit. Defaults to 0.5.
+	FailureRate float64
+	// MinRequests is the number of requests in the window needed before
+	// the failure rate is checked. Defaults to 10.
+	MinRequests int
+	// Window is the period failures are counted over. Defaults to 1 minute.
+	Window time.Duration
+	// OpenTimeout is how long a circuit stays open before letting probes
+	// through. Defaults to 30 seconds.
+	OpenTimeout time.Duration
+	// HalfOpenRequests is the number of probes that must succeed to close
+	// a circuit. Defaults to 1.
+	HalfOpenRequests int
+	// ByRoute keys circuits by host and path template, for requests sent
+	// with PathParams, rather than by host alone.
+	ByRoute bool
+	// IsFailure reports whether a request failed. Defaults to errors other
+	// than cancellation, and 5xx and 429 responses. Attempts that fail
+	// before they are sent, such as while waiting on a RateLimiter, are
+	// not counted.
+	IsFailure func(res *http.Response, err error) bool
+	// OnStateChange, when set, is called when a circuit changes state.
+	// It is called with the breaker locked, so must not call State.
+	OnStateChange func(key string, from, to CircuitState)
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	state      CircuitState
+	generation uint64 // incremented on every state change
+	openedAt   time.Time
+	buckets    [circuitBuckets]circuitBucket
+	probes     int // probes in flight while half-open
+	passed     int // probes that succeeded while half-open
+}
+
+type circuitBucket struct {
+	start     time.Time
+	successes int
+	failures  int
+}
+
+// State returns the state of the circuit for key, which is a host, or a
+// host followed by a path template when ByRoute is set.
+func (b *CircuitBreaker) State(key string) CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuits[key]
+	if c == nil {
+		return CircuitClosed
+	}
+	if c.state == CircuitOpen && b.now().Sub(c.openedAt) >= b.openTimeout() {
+		return CircuitHalfOpen
+	}
+	return c.state
+}
+
+// key returns the circuit key of a request to host.
+func (b *CircuitBreaker) key(host string, request Request) string {
+	if b.ByRoute {
+		return host + routeOf(request)
+	}
+	return host
+}
+
+// allow returns ErrCircuitOpen if a request for key must not be sent.
+// Otherwise it returns the generation of the circuit, which must be passed
+// to done or release.
+func (b *CircuitBreaker) allow(key string) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuit(key)
+	if c.state == CircuitOpen {
+		if b.now().Sub(c.openedAt) < b.openTimeout() {
+			return 0, ErrCircuitOpen
+		}
+		b.setState(key, c, CircuitHalfOpen)
+	}
+	if c.state == CircuitHalfOpen {
+		if c.probes+c.passed >= b.halfOpenRequests() {
+			return 0, ErrCircuitOpen
+		}
+		c.probes++
+	}
+	return c.generation, nil
+}
+
+// done records the outcome of a request allowed for key in generation.
+// Outcomes of requests allowed before the circuit last changed state are
+// ignored, so a slow success from before the circuit opened cannot close it.
+func (b *CircuitBreaker) done(key string, generation uint64, res *http.Response, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuit(key)
+	if c.generation != generation {
+		return
+	}
+
+	// A request cancelled by the caller says nothing about the server.
+	ignored := errors.Is(err, context.Canceled)
+	failed := !ignored && b.isFailure(res, err)
+
+	switch c.state {
+	case CircuitHalfOpen:
+		if c.probes > 0 {
+			c.probes--
+		}
+		switch {
+		case ignored:
+		case failed:
+			b.open(key, c)
+		default:
+			c.passed++
+			if c.passed >= b.halfOpenRequests() {
+				b.setState(key, c, CircuitClosed)
+			}
+		}
+	case CircuitClosed:
+		if ignored {
+			return
+		}
+		bucket := b.bucket(c)
+		if failed {
+			bucket.failures++
+		} else {
+			bucket.successes++
+		}
+		b.checkRate(key, c)
+	}
+}
+
+// release gives back a request allowed for key in generation that was
+// never sent.
+func (b *CircuitBreaker) release(key string, generation uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuit(key)
+	if c.generation == generation && c.state == CircuitHalfOpen && c.probes > 0 {
+		c.probes--
+	}
+}
+
+// checkRate opens a closed circuit whose failure rate is too high.
+func (b *CircuitBreaker) checkRate(key string, c *circuit) {
+	cutoff := b.now().Add(-b.window())
+	var successes, failures int
+	for _, bucket := range c.buckets {
+		if bucket.start.After(cutoff) {
+			successes += bucket.successes
+			failures += bucket.failures
+		}
+	}
+	total := successes + failures
+	if total < b.minRequests() {
+		return
+	}
+	if float64(failures)/float64(total) >= b.failureRate() {
+		b.open(key, c)
+	}
+}
+
+func (b *CircuitBreaker) open(key string, c *circuit) {
+	c.openedAt = b.now()
+	b.setState(key, c, CircuitOpen)
+}
+
+func (b *CircuitBreaker) setState(key string, c *circuit, state CircuitState) {
+	from := c.state
+	c.state = state
+	c.generation++
+	c.probes, c.passed = 0, 0
+	if state == CircuitClosed {
+		c.buckets = [circuitBuckets]circuitBucket{}
+	}
+	if b.OnStateChange != nil && from != state {
+		b.OnStateChange(key, from, state)
+	}
+}
+
+// bucket returns the bucket of the rolling window for the current time.
+func (b *CircuitBreaker) bucket(c *circuit) *circuitBucket {
+	width := b.window() / circuitBuckets
+	if width <= 0 {
+		width = 1
+	}
+	now := b.now()
+	start := now.Truncate(width)
+	bucket := &c.buckets[(start.UnixNano()/int64(width))%circuitBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = circuitBucket{start: start}
+	}
+	return bucket
+}
+
+func (b *CircuitBreaker) circuit(key string) *circuit {
+	if b.circuits == nil {
+		b.circuits = make(map[string]*circuit)
+	}
+	c := b.circuits[key]
+	if c == nil {
+		c = &circuit{}
+		b.circuits[key] = c
+	}
+	return c
+}
+
+func (b *CircuitBreaker) isFailure(res *http.Response, err error) bool {
+	if b.IsFailure != nil {
+		return b.IsFailure(res, err)
+	}
+	if err != nil {
+		return true
+	}
+	return res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests
+}
+
+func (b *CircuitBreaker) now() time.Time {
+	if b.Now != nil {
+		return b.Now()
+	}
+	return time.Now()
+}
+
+func (b *CircuitBreaker) failureRate() float64 {
+	if b.FailureRate <= 0 {
+		return 0.5
+	}
+	return b.FailureRate
+}
+
+func (b *CircuitBreaker) minRequests() int {
+	if b.MinRequests <= 0 {
+		return 10
+	}
+	return b.MinRequests
+}
+
+func (b *CircuitBreaker) window() time.Duration {
+	if b.Window <= 0 {
+		return time.Minute
+	}
+	return b.Window
+}
+
+func (b *CircuitBreaker) openTimeout() time.Duration {
+	if b.OpenTimeout <= 0 {
+		return 30 * time.Second
+	}
+	return b.OpenTimeout
+}
+
+func (b *CircuitBreaker) halfOpenRequests() int {
+	if b.HalfOpenRequests <= 0 {
+		return 1
+	}
+	return b.HalfOpenRequests
+}
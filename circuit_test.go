@@ -0,0 +1,216 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Parallel()
+	var calls, healthy int32
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer fakeServer.Close()
+
+	now := time.Now()
+	var changes []string
+	breaker := &CircuitBreaker{
+		MinRequests: 4,
+		OpenTimeout: time.Minute,
+		Now:         func() time.Time { return now },
+		OnStateChange: func(key string, from, to CircuitState) {
+			changes = append(changes, from.String()+" -> "+to.String())
+		},
+	}
+	client := &Client{HTTPClient: &http.Client{}, CircuitBreaker: breaker}
+	request := Request{Method: Get, BaseURL: fakeServer.URL}
+
+	for i := 0; i < 4; i++ {
+		if _, err := client.Send(request); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	host := fakeServer.Listener.Addr().String()
+	if state := breaker.State(host); state != CircuitOpen {
+		t.Fatalf("Expected an open circuit, got %s", state)
+	}
+	if _, err := client.Send(request); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 4 {
+		t.Errorf("Open circuit sent a request: %d calls", n)
+	}
+
+	// A failed probe opens the circuit again.
+	now = now.Add(time.Minute)
+	if state := breaker.State(host); state != CircuitHalfOpen {
+		t.Errorf("Expected a half-open circuit, got %s", state)
+	}
+	client.Send(request) // nolint
+	if state := breaker.State(host); state != CircuitOpen {
+		t.Errorf("Expected an open circuit after a failed probe, got %s", state)
+	}
+
+	// A successful probe closes it.
+	now = now.Add(time.Minute)
+	atomic.StoreInt32(&healthy, 1)
+	if _, err := client.Send(request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if state := breaker.State(host); state != CircuitClosed {
+		t.Errorf("Expected a closed circuit, got %s", state)
+	}
+
+	expected := []string{"closed -> open", "open -> half-open", "half-open -> open", "open -> half-open", "half-open -> closed"}
+	if len(changes) != len(expected) {
+		t.Fatalf("Wrong state changes: %v", changes)
+	}
+	for i := range expected {
+		if changes[i] != expected[i] {
+			t.Errorf("Wrong state changes: %v", changes)
+			break
+		}
+	}
+}
+
+func TestCircuitBreakerFailureRate(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	breaker := &CircuitBreaker{MinRequests: 4, FailureRate: 0.5, Window: 10 * time.Second, Now: func() time.Time { return now }}
+	ok := &http.Response{StatusCode: http.StatusOK}
+	failed := &http.Response{StatusCode: http.StatusInternalServerError}
+
+	for _, res := range []*http.Response{ok, ok, ok, failed} {
+		generation, err := breaker.allow("host")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		breaker.done("host", generation, res, nil)
+	}
+	if state := breaker.State("host"); state != CircuitClosed {
+		t.Errorf("Circuit opened below the failure rate: %s", state)
+	}
+
+	// Old successes fall out of the window.
+	now = now.Add(15 * time.Second)
+	for _, res := range []*http.Response{ok, failed, failed, failed} {
+		generation, _ := breaker.allow("host")
+		breaker.done("host", generation, res, nil)
+	}
+	if state := breaker.State("host"); state != CircuitOpen {
+		t.Errorf("Circuit did not open above the failure rate: %s", state)
+	}
+	if state := breaker.State("other"); state != CircuitClosed {
+		t.Errorf("Circuits are not per host: %s", state)
+	}
+}
+
+func TestCircuitBreakerStaleOutcomes(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	breaker := &CircuitBreaker{MinRequests: 2, OpenTimeout: time.Minute, Now: func() time.Time { return now }}
+	ok := &http.Response{StatusCode: http.StatusOK}
+	failed := &http.Response{StatusCode: http.StatusInternalServerError}
+
+	// A slow request is allowed while the circuit is closed...
+	slow, err := breaker.allow("host")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		generation, _ := breaker.allow("host")
+		breaker.done("host", generation, failed, nil)
+	}
+	if state := breaker.State("host"); state != CircuitOpen {
+		t.Fatalf("Expected an open circuit, got %s", state)
+	}
+
+	// ...and succeeds while a probe is in flight.
+	now = now.Add(time.Minute)
+	probe, err := breaker.allow("host")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	breaker.done("host", slow, ok, nil)
+	breaker.release("host", slow)
+	if state := breaker.State("host"); state != CircuitHalfOpen {
+		t.Errorf("A stale success changed the circuit to %s", state)
+	}
+	if _, err := breaker.allow("host"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("A stale outcome freed the probe slot: %v", err)
+	}
+
+	breaker.done("host", probe, failed, nil)
+	if state := breaker.State("host"); state != CircuitOpen {
+		t.Errorf("Expected an open circuit after a failed probe, got %s", state)
+	}
+	breaker.done("host", probe, ok, nil)
+	if state := breaker.State("host"); state != CircuitOpen {
+		t.Errorf("A stale probe changed the circuit to %s", state)
+	}
+}
+
+func TestCircuitBreakerWaitFailures(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer fakeServer.Close()
+
+	breaker := &CircuitBreaker{MinRequests: 1}
+	client := &Client{
+		HTTPClient:     &http.Client{},
+		CircuitBreaker: breaker,
+		RateLimiter:    &RateLimiter{Default: Limit{Rate: 0.01, Burst: 1}},
+	}
+	request := Request{Method: Get, BaseURL: fakeServer.URL}
+	if _, err := client.Send(request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Attempts that time out waiting for the client's own limits never
+	// reach the server, so they are not failures.
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		_, err := client.SendWithContext(ctx, request)
+		cancel()
+		if err == nil {
+			t.Fatal("Expected the rate limiter to fail the request")
+		}
+	}
+	if state := breaker.State(fakeServer.Listener.Addr().String()); state != CircuitClosed {
+		t.Errorf("Wait failures changed the circuit to %s", state)
+	}
+
+	// A probe that is never sent gives its slot back.
+	now := time.Now()
+	breaker = &CircuitBreaker{MinRequests: 1, OpenTimeout: time.Minute, Now: func() time.Time { return now }}
+	generation, _ := breaker.allow("host")
+	breaker.done("host", generation, &http.Response{StatusCode: http.StatusInternalServerError}, nil)
+	now = now.Add(time.Minute)
+	generation, err := breaker.allow("host")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	breaker.release("host", generation)
+	if _, err := breaker.allow("host"); err != nil {
+		t.Errorf("A released probe was not given back: %v", err)
+	}
+}
+
+func TestCircuitBreakerByRoute(t *testing.T) {
+	t.Parallel()
+	breaker := &CircuitBreaker{ByRoute: true}
+	request := Request{BaseURL: "https://api.example.com/v3/items/{id}", PathParams: map[string]string{"id": "1"}}
+	if key := breaker.key("api.example.com", request); key != "api.example.com/v3/items/{id}" {
+		t.Errorf("Wrong circuit key: %s", key)
+	}
+}
@@ -0,0 +1,160 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Problem is the decoded body of an error response. It holds the fields of
+// an RFC 7807 application/problem+json document and the per-field errors of
+// the SendGrid {"errors": [...]} shape.
+type Problem struct {
+	Type     string       `json:"type,omitempty"`
+	Title    string       `json:"title,omitempty"`
+	Status   int          `json:"status,omitempty"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes a single error in a Problem, e.g. an invalid field.
+type FieldError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// message returns the most descriptive text in the problem.
+func (p *Problem) message() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	if p.Title != "" {
+		return p.Title
+	}
+	messages := make([]string, 0, len(p.Errors))
+	for _, e := range p.Errors {
+		if e.Field != "" {
+			messages = append(messages, e.Field+": "+e.Message)
+		} else {
+			messages = append(messages, e.Message)
+		}
+	}
+	return strings.Join(messages, "; ")
+}
+
+// ProblemDecoder decodes an error response body into a Problem.
+// It returns nil if the body does not have the expected shape.
+type ProblemDecoder func(body []byte) *Problem
+
+var (
+	problemDecodersMu sync.RWMutex
+	problemDecoders   = map[string]ProblemDecoder{
+		"application/json":         DecodeJSONProblem,
+		"application/problem+json": DecodeJSONProblem,
+	}
+)
+
+// RegisterProblemDecoder sets the decoder used for error responses with the
+// given media type, e.g. "application/vnd.example+json", replacing any
+// decoder already registered for it.
+func RegisterProblemDecoder(mediaType string, decoder ProblemDecoder) {
+	problemDecodersMu.Lock()
+	defer problemDecodersMu.Unlock()
+	problemDecoders[strings.ToLower(mediaType)] = decoder
+}
+
+// DecodeJSONProblem decodes an RFC 7807 problem document or a SendGrid
+// style {"errors": [{"field": ..., "message": ...}]} body.
+func DecodeJSONProblem(body []byte) *Problem {
+	var p Problem
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil
+	}
+	if p.Type == "" && p.Title == "" && p.Detail == "" && len(p.Errors) == 0 {
+		return nil
+	}
+	return &p
+}
+
+// newRestError builds the error returned for a non-2xx response.
+func newRestError(request Request, response *Response) *RestError {
+	return &RestError{
+		Response:   response,
+		StatusCode: response.StatusCode,
+		Method:     request.Method,
+		URL:        requestURL(request),
+		Problem:    decodeProblem(http.Header(response.Headers).Get("Content-Type"), response.Body),
+	}
+}
+
+// decodeProblem decodes an error body with the decoder registered for its
+// content type. Unregistered +json media types fall back to the
+// application/json decoder.
+func decodeProblem(contentType, body string) *Problem {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+	problemDecodersMu.RLock()
+	decoder, ok := problemDecoders[mediaType]
+	if !ok && strings.HasSuffix(mediaType, "+json") {
+		decoder, ok = problemDecoders["application/json"]
+	}
+	problemDecodersMu.RUnlock()
+	if !ok {
+		return nil
+	}
+	return decoder([]byte(body))
+}
+
+// statusCode returns the status code carried by a *RestError in err's
+// chain, or 0 if there is none.
+func statusCode(err error) int {
+	var restErr *RestError
+	if !errors.As(err, &restErr) {
+		return 0
+	}
+	if restErr.StatusCode != 0 {
+		return restErr.StatusCode
+	}
+	if restErr.Response != nil {
+		return restErr.Response.StatusCode
+	}
+	return 0
+}
+
+// IsNotFound reports whether err is a *RestError for a 404 response.
+func IsNotFound(err error) bool {
+	return statusCode(err) == http.StatusNotFound
+}
+
+// IsUnauthorized reports whether err is a *RestError for a 401 response.
+func IsUnauthorized(err error) bool {
+	return statusCode(err) == http.StatusUnauthorized
+}
+
+// IsForbidden reports whether err is a *RestError for a 403 response.
+func IsForbidden(err error) bool {
+	return statusCode(err) == http.StatusForbidden
+}
+
+// IsRateLimited reports whether err is a *RestError for a 429 response.
+func IsRateLimited(err error) bool {
+	return statusCode(err) == http.StatusTooManyRequests
+}
+
+// IsClientError reports whether err is a *RestError for a 4xx response.
+func IsClientError(err error) bool {
+	code := statusCode(err)
+	return code >= 400 && code < 500
+}
+
+// IsServerError reports whether err is a *RestError for a 5xx response.
+func IsServerError(err error) bool {
+	code := statusCode(err)
+	return code >= 500 && code < 600
+}
@@ -0,0 +1,156 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorOnStatus(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintln(w, `{"title": "Not Found", "detail": "api key does not exist"}`)
+	}))
+	defer fakeServer.Close()
+
+	client := &Client{HTTPClient: &http.Client{}, ErrorOnStatus: true}
+	response, err := client.Send(Request{
+		Method:      Get,
+		BaseURL:     fakeServer.URL + "/v3/api_keys/1",
+		QueryParams: map[string]string{"limit": "1"},
+	})
+	if err == nil {
+		t.Fatal("Expected an error for a 404 response")
+	}
+	if response == nil || response.StatusCode != http.StatusNotFound {
+		t.Error("The Response should be returned along with the error")
+	}
+
+	var restErr *RestError
+	if !errors.As(err, &restErr) {
+		t.Fatalf("Expected a *RestError, got %T", err)
+	}
+	if restErr.StatusCode != http.StatusNotFound || restErr.Method != Get {
+		t.Errorf("Invalid status or method: %d %s", restErr.StatusCode, restErr.Method)
+	}
+	if restErr.URL != fakeServer.URL+"/v3/api_keys/1?limit=1" {
+		t.Errorf("Invalid URL: %s", restErr.URL)
+	}
+	if restErr.Problem == nil || restErr.Problem.Detail != "api key does not exist" {
+		t.Errorf("Problem body was not decoded: %+v", restErr.Problem)
+	}
+	if !strings.Contains(err.Error(), "404 Not Found: api key does not exist") {
+		t.Errorf("Invalid error message: %s", err)
+	}
+	if !IsNotFound(err) || !IsClientError(err) || IsServerError(err) {
+		t.Error("Status helpers did not classify the 404 error")
+	}
+}
+
+func TestErrorOnStatusSuccess(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer fakeServer.Close()
+
+	client := &Client{HTTPClient: &http.Client{}, ErrorOnStatus: true}
+	if _, err := client.Send(Request{Method: Post, BaseURL: fakeServer.URL}); err != nil {
+		t.Errorf("A 2xx response should not be an error, got %v", err)
+	}
+}
+
+func TestStatusHelpers(t *testing.T) {
+	t.Parallel()
+	errFor := func(code int) error {
+		return fmt.Errorf("wrapped: %w", &RestError{Response: &Response{StatusCode: code}})
+	}
+	if !IsRateLimited(errFor(http.StatusTooManyRequests)) {
+		t.Error("IsRateLimited did not match a 429")
+	}
+	if !IsUnauthorized(errFor(http.StatusUnauthorized)) {
+		t.Error("IsUnauthorized did not match a 401")
+	}
+	if !IsForbidden(errFor(http.StatusForbidden)) {
+		t.Error("IsForbidden did not match a 403")
+	}
+	if !IsServerError(errFor(http.StatusServiceUnavailable)) {
+		t.Error("IsServerError did not match a 503")
+	}
+	if !IsNotFound(&RestError{StatusCode: http.StatusNotFound}) {
+		t.Error("IsNotFound did not match a RestError without a Response")
+	}
+	if !IsServerError(&RestError{Response: &Response{StatusCode: http.StatusBadGateway}}) {
+		t.Error("IsServerError did not match a RestError built from a Response")
+	}
+	if IsNotFound(errors.New("test error")) || IsServerError(nil) {
+		t.Error("Helpers should not match errors without a *RestError")
+	}
+}
+
+func TestDecodeSendGridErrors(t *testing.T) {
+	t.Parallel()
+	body := `{"errors": [{"field": "name", "message": "is required"}, {"field": null, "message": "bad request"}]}`
+	p := decodeProblem("application/json; charset=utf-8", body)
+	if p == nil || len(p.Errors) != 2 {
+		t.Fatalf("SendGrid errors were not decoded: %+v", p)
+	}
+	if p.Errors[0].Field != "name" || p.Errors[0].Message != "is required" {
+		t.Errorf("Invalid field error: %+v", p.Errors[0])
+	}
+	if p.message() != "name: is required; bad request" {
+		t.Errorf("Invalid problem message: %q", p.message())
+	}
+}
+
+func TestDecodeProblemJSON(t *testing.T) {
+	t.Parallel()
+	body := `{"type": "https://example.com/probs/out-of-credit", "title": "You do not have enough credit.",
+		"status": 403, "detail": "Your current balance is 30.", "instance": "/account/12345/msgs/abc"}`
+	p := decodeProblem("application/problem+json", body)
+	if p == nil {
+		t.Fatal("Problem document was not decoded")
+	}
+	if p.Type != "https://example.com/probs/out-of-credit" || p.Status != 403 || p.Instance != "/account/12345/msgs/abc" {
+		t.Errorf("Invalid problem: %+v", p)
+	}
+	if p.message() != "Your current balance is 30." {
+		t.Errorf("Invalid problem message: %q", p.message())
+	}
+
+	if decodeProblem("text/plain", "Not Found") != nil {
+		t.Error("Plain text bodies should not be decoded")
+	}
+	if decodeProblem("application/json", `{"result": "failure"}`) != nil {
+		t.Error("JSON bodies without problem fields should not be decoded")
+	}
+}
+
+func TestRegisterProblemDecoder(t *testing.T) {
+	t.Parallel()
+	RegisterProblemDecoder("application/vnd.rest-test+json", func(body []byte) *Problem {
+		var v struct {
+			Code string `json:"code"`
+			Msg  string `json:"msg"`
+		}
+		if err := json.Unmarshal(body, &v); err != nil {
+			return nil
+		}
+		return &Problem{Type: v.Code, Detail: v.Msg}
+	})
+	p := decodeProblem("application/vnd.rest-test+json", `{"code": "E42", "msg": "quota exceeded"}`)
+	if p == nil || p.Type != "E42" || p.Detail != "quota exceeded" {
+		t.Errorf("Custom decoder was not used: %+v", p)
+	}
+
+	p = decodeProblem("application/vnd.other+json", `{"title": "Bad Request"}`)
+	if p == nil || p.Title != "Bad Request" {
+		t.Errorf("+json media types should fall back to the JSON decoder: %+v", p)
+	}
+}
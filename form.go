@@ -0,0 +1,118 @@
+package rest
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// SetForm makes values the application/x-www-form-urlencoded body of the
+// request and sets its Content-Type.
+func (r *Request) SetForm(values url.Values) {
+	r.setHeader("Content-Type", "application/x-www-form-urlencoded")
+	r.Body = []byte(values.Encode())
+	r.BodyReader = nil
+	r.ContentLength = 0
+	r.GetBody = nil
+}
+
+// EncodeForm converts a struct, or a pointer to one, into url.Values using
+// the "form" struct tag:
+//
+//	type TokenRequest struct {
+//		GrantType string   `form:"grant_type"`
+//		Scope     []string `form:"scope,omitempty"`
+//	}
+//
+// Fields tagged "-" are skipped and fields without a tag use their name.
+// Slices add one value per element.
+func EncodeForm(v interface{}) (url.Values, error) {
+	values := url.Values{}
+	if err := encodeValues(v, "form", values.Add); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// encodeValues calls add for every value of a struct's fields, in field
+// order, naming them with the given struct tag.
+func encodeValues(v interface{}, tag string, add func(key, value string)) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("rest: cannot encode %T, want a struct", v)
+	}
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+		name, opts := field.Name, ""
+		if t, ok := field.Tag.Lookup(tag); ok {
+			if t == "-" {
+				continue
+			}
+			name, opts = t, ""
+			if idx := strings.Index(t, ","); idx >= 0 {
+				name, opts = t[:idx], t[idx+1:]
+			}
+			if name == "" {
+				name = field.Name
+			}
+		}
+		fv := rv.Field(i)
+		if strings.Contains(opts, "omitempty") && fv.IsZero() {
+			continue
+		}
+		if err := addValue(add, name, fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addValue adds the string form of fv under name.
+func addValue(add func(key, value string), name string, fv reflect.Value) error {
+	switch fv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if fv.IsNil() {
+			return nil
+		}
+		return addValue(add, name, fv.Elem())
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < fv.Len(); i++ {
+			if err := addValue(add, name, fv.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if s, ok := fv.Interface().(fmt.Stringer); ok {
+		add(name, s.String())
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		add(name, fv.String())
+	case reflect.Bool:
+		add(name, strconv.FormatBool(fv.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		add(name, strconv.FormatInt(fv.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		add(name, strconv.FormatUint(fv.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		add(name, strconv.FormatFloat(fv.Float(), 'f', -1, fv.Type().Bits()))
+	default:
+		return fmt.Errorf("rest: cannot encode field %s of type %s", name, fv.Type())
+	}
+	return nil
+}
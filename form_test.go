@@ -0,0 +1,69 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestSetForm(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+			t.Errorf("Invalid Content-Type: %s", r.Header.Get("Content-Type"))
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("Invalid form body: %v", err)
+		}
+		if r.PostForm.Get("grant_type") != "client_credentials" || len(r.PostForm["scope"]) != 2 {
+			t.Errorf("Invalid form values: %v", r.PostForm)
+		}
+	}))
+	defer fakeServer.Close()
+
+	request := Request{Method: Post, BaseURL: fakeServer.URL}
+	request.SetForm(url.Values{
+		"grant_type": {"client_credentials"},
+		"scope":      {"mail.send", "alerts.read"},
+	})
+	if _, err := Send(request); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestEncodeForm(t *testing.T) {
+	t.Parallel()
+	limit := 10
+	type tokenRequest struct {
+		GrantType string   `form:"grant_type"`
+		Scope     []string `form:"scope,omitempty"`
+		Limit     *int     `form:"limit"`
+		Offset    int      `form:"offset,omitempty"`
+		Active    bool
+		Secret    string `form:"-"`
+		internal  string
+	}
+	values, err := EncodeForm(&tokenRequest{
+		GrantType: "refresh_token",
+		Scope:     []string{"a", "b"},
+		Limit:     &limit,
+		Active:    true,
+		Secret:    "shh",
+		internal:  "x",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "Active=true&grant_type=refresh_token&limit=10&scope=a&scope=b"
+	if values.Encode() != expected {
+		t.Errorf("EncodeForm() = %s, want %s", values.Encode(), expected)
+	}
+
+	if _, err := EncodeForm("not a struct"); err == nil {
+		t.Error("Expected an error for a non-struct value")
+	}
+	if _, err := EncodeForm(struct{ C chan int }{}); err == nil {
+		t.Error("Expected an error for an unsupported field type")
+	}
+}
@@ -0,0 +1,76 @@
+//go:build go1.18
+// +build go1.18
+
+package rest
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// SendJSON encodes body as JSON, sends it to url with client and decodes a
+// successful response body into Resp. A nil client uses the DefaultClient
+// and a nil body, including a nil pointer, map or slice, sends no request
+// body.
+//
+// A non-2xx response is returned as a *RestError, along with the Response.
+func SendJSON[Req, Resp any](ctx context.Context, client *Client, method Method, url string, body Req) (Resp, *Response, error) {
+	return SendJSONRequest[Req, Resp](ctx, client, Request{Method: method, BaseURL: url}, body)
+}
+
+// GetJSON sends a GET request to url with client and decodes a successful
+// response body into Resp.
+func GetJSON[Resp any](ctx context.Context, client *Client, url string) (Resp, *Response, error) {
+	return SendJSONRequest[any, Resp](ctx, client, Request{Method: Get, BaseURL: url}, nil)
+}
+
+// SendJSONRequest is like SendJSON but takes a Request, so headers and query
+// parameters can be set. The request's Body is replaced by the encoded body.
+func SendJSONRequest[Req, Resp any](ctx context.Context, client *Client, request Request, body Req) (Resp, *Response, error) {
+	var result Resp
+	if client == nil {
+		client = DefaultClient
+	}
+
+	headers := make(map[string]string, len(request.Headers)+2)
+	for key, value := range request.Headers {
+		headers[key] = value
+	}
+	if !hasHeader(headers, "Accept") {
+		headers["Accept"] = "application/json"
+	}
+	request.Headers = headers
+
+	request.Body = nil
+	if any(body) != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return result, nil, err
+		}
+		// A typed nil, such as a nil pointer, encodes as null.
+		if string(b) == "null" {
+			b = nil
+		}
+		request.Body = b
+	}
+	if len(request.Body) > 0 {
+		if !hasHeader(headers, "Content-Type") {
+			headers["Content-Type"] = "application/json"
+		}
+	}
+
+	response, err := client.SendWithContext(ctx, request)
+	if err != nil {
+		return result, response, err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return result, response, newRestError(request, response)
+	}
+	if len(response.Body) == 0 {
+		return result, response, nil
+	}
+	if err := json.Unmarshal([]byte(response.Body), &result); err != nil {
+		return result, response, err
+	}
+	return result, response, nil
+}
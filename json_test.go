@@ -0,0 +1,136 @@
+//go:build go1.18
+// +build go1.18
+
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+type apiKey struct {
+	ID     string   `json:"api_key_id,omitempty"`
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+func TestSendJSON(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Accept") != "application/json" {
+			t.Errorf("Invalid JSON headers: %v", r.Header)
+		}
+		var key apiKey
+		if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		key.ID = "abc"
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(key) // nolint
+	}))
+	defer fakeServer.Close()
+
+	key, response, err := SendJSON[apiKey, apiKey](context.Background(), nil, Post, fakeServer.URL,
+		apiKey{Name: "My API Key", Scopes: []string{"mail.send"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.StatusCode != http.StatusCreated {
+		t.Errorf("Invalid status code: %d", response.StatusCode)
+	}
+	if key.ID != "abc" || key.Name != "My API Key" {
+		t.Errorf("Invalid decoded response: %+v", key)
+	}
+}
+
+func TestGetJSON(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength != 0 {
+			t.Error("A GET request should not have a body")
+		}
+		fmt.Fprintln(w, `{"result": [{"api_key_id": "abc", "name": "My API Key"}]}`)
+	}))
+	defer fakeServer.Close()
+
+	type keys struct {
+		Result []apiKey `json:"result"`
+	}
+	result, _, err := GetJSON[keys](context.Background(), nil, fakeServer.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Result) != 1 || result.Result[0].ID != "abc" {
+		t.Errorf("Invalid decoded response: %+v", result)
+	}
+}
+
+func TestSendJSONError(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, `{"errors": [{"field": "name", "message": "is required"}]}`)
+	}))
+	defer fakeServer.Close()
+
+	request := Request{
+		Method:  Post,
+		BaseURL: fakeServer.URL,
+		Headers: map[string]string{"Authorization": "Bearer API_KEY"},
+	}
+	_, response, err := SendJSONRequest[apiKey, apiKey](context.Background(), DefaultClient, request, apiKey{})
+	if response == nil || response.StatusCode != http.StatusBadRequest {
+		t.Error("The Response should be returned along with the error")
+	}
+	var restErr *RestError
+	if !errors.As(err, &restErr) {
+		t.Fatalf("Expected a *RestError, got %v", err)
+	}
+	if restErr.Problem == nil || len(restErr.Problem.Errors) != 1 || restErr.Problem.Errors[0].Field != "name" {
+		t.Errorf("Error body was not decoded: %+v", restErr.Problem)
+	}
+}
+
+func TestSendJSONTypedNil(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength != 0 || r.Header.Get("Content-Type") != "" {
+			t.Errorf("A nil pointer body should not be sent: %d bytes, %q", r.ContentLength, r.Header.Get("Content-Type"))
+		}
+	}))
+	defer fakeServer.Close()
+
+	var body *apiKey
+	if _, _, err := SendJSON[*apiKey, any](context.Background(), nil, Post, fakeServer.URL, body); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestSendJSONHeaderCase(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "text/csv" || r.Header.Get("Content-Type") != "text/plain" {
+			t.Errorf("Caller headers were overridden: %v", r.Header)
+		}
+	}))
+	defer fakeServer.Close()
+
+	request := Request{
+		Method:  Post,
+		BaseURL: fakeServer.URL,
+		Headers: map[string]string{"accept": "text/csv", "content-type": "text/plain"},
+	}
+	// Headers are set in map order, so repeat to catch duplicates.
+	for i := 0; i < 10; i++ {
+		if _, _, err := SendJSONRequest[string, any](context.Background(), nil, request, "data"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+}
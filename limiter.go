@@ -0,0 +1,115 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit is the rate of a token bucket.
+type Limit struct {
+	// Rate is the number of tokens added per second.
+	Rate float64
+	// Burst is the size of the bucket, the most tokens that can be used at
+	// once. Defaults to Rate rounded up, or 1.
+	Burst int
+}
+
+// RateLimiter caps the rate of outgoing requests with a token bucket per
+// host, or per host and path template. Every attempt, retries included,
+// waits until its host's bucket has enough tokens or the request's context
+// is done.
+//
+// Limits are looked up by host followed by the path template of requests
+// sent with PathParams, such as "api.sendgrid.com/v3/mail/send", then by
+// host alone, such as "api.sendgrid.com", before falling back to Default.
+// Requests with no limit are not delayed.
+//
+// It is safe for concurrent use.
+type RateLimiter struct {
+	// Default is the limit for hosts not in Limits. A zero Rate means
+	// requests to those hosts are not limited.
+	Default Limit
+	// Limits holds the limits for hosts and routes.
+	Limits map[string]Limit
+	// Weight returns the number of tokens a request uses. Defaults to 1.
+	Weight func(request Request) int
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	limit  Limit
+	tokens float64
+	last   time.Time
+}
+
+// waitRequest waits for the tokens of a request to host.
+func (l *RateLimiter) waitRequest(ctx context.Context, host string, request Request) error {
+	n := 1
+	if l.Weight != nil {
+		n = l.Weight(request)
+	}
+	if n <= 0 {
+		return nil
+	}
+	if route := routeOf(request); route != "" {
+		if _, ok := l.Limits[host+route]; ok {
+			return l.Wait(ctx, host+route, n)
+		}
+	}
+	return l.Wait(ctx, host, n)
+}
+
+// Wait takes n tokens from the bucket for key, a host or a host and route,
+// blocking until they are available or ctx is done. It returns an error
+// without waiting if n is more than the bucket's burst size.
+func (l *RateLimiter) Wait(ctx context.Context, key string, n int) error {
+	limit, ok := l.Limits[key]
+	if !ok {
+		limit = l.Default
+	}
+	if limit.Rate <= 0 {
+		return nil
+	}
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = int(math.Max(1, math.Ceil(limit.Rate)))
+	}
+	if n > burst {
+		return fmt.Errorf("rest: request weight %d exceeds burst %d for %s", n, burst, key)
+	}
+
+	l.mu.Lock()
+	if l.buckets == nil {
+		l.buckets = make(map[string]*tokenBucket)
+	}
+	now := time.Now()
+	b := l.buckets[key]
+	if b == nil || b.limit != limit {
+		b = &tokenBucket{limit: limit, tokens: float64(burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+	// Take the tokens now, going into debt if need be, so that waiting
+	// requests are served in order.
+	b.tokens -= float64(n)
+	delay := time.Duration(-b.tokens / limit.Rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	if err := sleep(ctx, delay); err != nil {
+		// Give back the tokens that were never used.
+		l.mu.Lock()
+		b.tokens += float64(n)
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestRateLimiter(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer fakeServer.Close()
+
+	client := &Client{
+		HTTPClient:  &http.Client{},
+		RateLimiter: &RateLimiter{Default: Limit{Rate: 20, Burst: 2}},
+	}
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if _, err := client.Send(Request{Method: Get, BaseURL: fakeServer.URL}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	// Two requests use the burst and the other two wait 50ms each.
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("Requests were not limited: %v", elapsed)
+	}
+}
+
+func TestRateLimiterWeight(t *testing.T) {
+	t.Parallel()
+	limiter := &RateLimiter{
+		Limits: map[string]Limit{
+			"example.com":                 {Rate: 1000, Burst: 5},
+			"example.com/v3/mail/{batch}": {Rate: 1, Burst: 1},
+		},
+		Weight: func(request Request) int { return len(request.Body) },
+	}
+	ctx := context.Background()
+
+	if err := limiter.waitRequest(ctx, "example.com", Request{Body: []byte("12345")}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := limiter.waitRequest(ctx, "example.com", Request{Body: []byte("123456")}); err == nil || !strings.Contains(err.Error(), "exceeds burst") {
+		t.Errorf("Expected a burst error, got %v", err)
+	}
+
+	// The route has its own, slower bucket.
+	route := Request{BaseURL: "https://example.com/v3/mail/{batch}", PathParams: map[string]string{}, Body: []byte("1")}
+	if err := limiter.waitRequest(ctx, "example.com", route); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := limiter.waitRequest(timeout, "example.com", route); err != context.DeadlineExceeded {
+		t.Errorf("Expected the route limit to block, got %v", err)
+	}
+
+	// Unlisted hosts have no limit.
+	for i := 0; i < 10; i++ {
+		if err := limiter.Wait(ctx, "other.com", 100); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+}
+
+func TestRateLimiterCancelRefunds(t *testing.T) {
+	t.Parallel()
+	limiter := &RateLimiter{Default: Limit{Rate: 10, Burst: 1}}
+	ctx := context.Background()
+	if err := limiter.Wait(ctx, "example.com", 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	for i := 0; i < 5; i++ {
+		if err := limiter.Wait(cancelled, "example.com", 1); err != context.Canceled {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	}
+	// The cancelled waits did not use up tokens.
+	start := time.Now()
+	if err := limiter.Wait(ctx, "example.com", 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("Cancelled waits used tokens: waited %v", elapsed)
+	}
+}
@@ -0,0 +1,236 @@
+//go:build go1.21
+// +build go1.21
+
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// redacted replaces sensitive values in logs.
+const redacted = "REDACTED"
+
+// DefaultRedactedHeaders are always redacted by Logging.
+var DefaultRedactedHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+}
+
+// LogOptions configures the Logging middleware.
+type LogOptions struct {
+	// Level is the level of successful requests. Failed requests are
+	// logged at slog.LevelError.
+	Level slog.Level
+	// LogHeaders adds the request and response headers.
+	LogHeaders bool
+	// LogBodies adds the request and response bodies.
+	LogBodies bool
+	// RedactHeaders lists headers to redact, in addition to
+	// DefaultRedactedHeaders.
+	RedactHeaders []string
+	// RedactQueryParams lists query parameters to redact from the URL.
+	RedactQueryParams []string
+	// RedactBodyFields lists JSON object keys, at any depth, and form
+	// fields to redact from logged bodies.
+	RedactBodyFields []string
+}
+
+// Logging returns a Middleware that logs every request with logger: its
+// method, URL, status, latency and sizes, and optionally headers and bodies.
+func Logging(logger *slog.Logger, options LogOptions) Middleware {
+	headers := make(map[string]bool)
+	for _, list := range [][]string{DefaultRedactedHeaders, options.RedactHeaders} {
+		for _, h := range list {
+			headers[http.CanonicalHeaderKey(h)] = true
+		}
+	}
+	params := toSet(options.RedactQueryParams)
+	fields := toSet(options.RedactBodyFields)
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, request Request) (*Response, error) {
+			start := time.Now()
+			response, err := next(ctx, request)
+
+			attrs := []slog.Attr{
+				slog.String("method", string(request.Method)),
+				slog.String("url", redactURL(requestURL(request), params)),
+				slog.Duration("latency", time.Since(start)),
+				slog.Int64("request_size", requestSize(request)),
+			}
+			if options.LogHeaders {
+				attrs = append(attrs, headerAttr("request_headers", request.Headers, headers))
+			}
+			if options.LogBodies && len(request.Body) > 0 {
+				attrs = append(attrs, slog.String("request_body", redactBody(request.Body, headerValue(request.Headers, "Content-Type"), fields)))
+			}
+			if response != nil {
+				attrs = append(attrs,
+					slog.Int("status", response.StatusCode),
+					slog.Int("response_size", len(response.Body)))
+				if options.LogHeaders {
+					h := make(map[string]string, len(response.Headers))
+					for key, values := range response.Headers {
+						h[key] = strings.Join(values, ", ")
+					}
+					attrs = append(attrs, headerAttr("response_headers", h, headers))
+				}
+				if options.LogBodies && len(response.Body) > 0 {
+					attrs = append(attrs, slog.String("response_body", redactBody([]byte(response.Body), http.Header(response.Headers).Get("Content-Type"), fields)))
+				}
+			}
+
+			level := options.Level
+			if err != nil {
+				level = slog.LevelError
+				attrs = append(attrs, slog.String("error", redactError(err, params)))
+			}
+			logger.LogAttrs(ctx, level, "rest request", attrs...)
+			return response, err
+		}
+	}
+}
+
+// headerValue returns the value of key in headers, whatever the case of
+// their keys.
+func headerValue(headers map[string]string, key string) string {
+	for k, v := range headers {
+		if http.CanonicalHeaderKey(k) == http.CanonicalHeaderKey(key) {
+			return v
+		}
+	}
+	return ""
+}
+
+func toSet(keys []string) map[string]bool {
+	set := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		set[strings.ToLower(key)] = true
+	}
+	return set
+}
+
+func requestSize(request Request) int64 {
+	if request.BodyReader != nil || request.GetBody != nil {
+		return request.ContentLength
+	}
+	return int64(len(request.Body))
+}
+
+// headerAttr groups headers, redacting those in redact.
+func headerAttr(name string, headers map[string]string, redact map[string]bool) slog.Attr {
+	attrs := make([]any, 0, len(headers))
+	for key, value := range headers {
+		if redact[http.CanonicalHeaderKey(key)] {
+			value = redacted
+		}
+		attrs = append(attrs, slog.String(key, value))
+	}
+	return slog.Group(name, attrs...)
+}
+
+// redactURL replaces the values of the query parameters in redact.
+func redactURL(rawURL string, redact map[string]bool) string {
+	if len(redact) == 0 {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query, err := parseQuery(u.RawQuery)
+	if err != nil {
+		return rawURL
+	}
+	for i := range query {
+		if redact[strings.ToLower(query[i].Key)] {
+			query[i].Value = redacted
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// redactError returns the message of err with the query parameters in
+// redact removed from any URL it carries.
+func redactError(err error, redact map[string]bool) string {
+	msg := err.Error()
+	if len(redact) == 0 {
+		return msg
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		clean := *urlErr
+		clean.URL = redactURL(urlErr.URL, redact)
+		msg = strings.Replace(msg, urlErr.Error(), clean.Error(), 1)
+	}
+	var restErr *RestError
+	if errors.As(err, &restErr) && restErr.StatusCode != 0 {
+		clean := *restErr
+		clean.URL = redactURL(restErr.URL, redact)
+		msg = strings.Replace(msg, restErr.Error(), clean.Error(), 1)
+	}
+	return msg
+}
+
+// redactBody redacts the fields in redact from a JSON or form body. Other
+// bodies are returned unchanged.
+func redactBody(body []byte, contentType string, redact map[string]bool) string {
+	if len(redact) == 0 {
+		return string(body)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "application/x-www-form-urlencoded" {
+		return redactForm(string(body), redact)
+	}
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return string(body)
+	}
+	b, err := json.Marshal(redactValue(v, redact))
+	if err != nil {
+		return string(body)
+	}
+	return string(b)
+}
+
+// redactForm replaces the values of the form fields in redact, keeping the
+// rest of the body as it was sent.
+func redactForm(body string, redact map[string]bool) string {
+	pairs := strings.Split(body, "&")
+	for i, pair := range pairs {
+		key, _, _ := strings.Cut(pair, "=")
+		if name, err := url.QueryUnescape(key); err == nil && redact[strings.ToLower(name)] {
+			pairs[i] = key + "=" + redacted
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func redactValue(v any, redact map[string]bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if redact[strings.ToLower(key)] {
+				v[key] = redacted
+			} else {
+				v[key] = redactValue(value, redact)
+			}
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = redactValue(value, redact)
+		}
+	}
+	return v
+}
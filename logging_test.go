@@ -0,0 +1,187 @@
+//go:build go1.21
+// +build go1.21
+
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLogging(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret-cookie")
+		fmt.Fprint(w, `{"api_key": "secret-response-key", "name": "My API Key"}`)
+	}))
+	defer fakeServer.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	client := &Client{
+		HTTPClient: &http.Client{},
+		Middleware: []Middleware{Logging(logger, LogOptions{
+			LogHeaders:        true,
+			LogBodies:         true,
+			RedactQueryParams: []string{"token"},
+			RedactBodyFields:  []string{"password", "api_key"},
+		})},
+	}
+	request := Request{
+		Method:      Post,
+		BaseURL:     fakeServer.URL + "/v3/api_keys",
+		Headers:     map[string]string{"Authorization": "Bearer secret-api-key", "X-Test": "visible"},
+		QueryParams: map[string]string{"token": "secret-token", "limit": "10"},
+		Body:        []byte(`{"user": {"password": "secret-password"}, "name": "My API Key"}`),
+	}
+	if _, err := client.Send(request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	out := buf.String()
+	for _, secret := range []string{"secret-api-key", "secret-token", "secret-password", "secret-cookie", "secret-response-key"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("Log leaked %s: %s", secret, out)
+		}
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Invalid log record: %v", err)
+	}
+	if record["msg"] != "rest request" || record["method"] != "POST" || record["status"] != float64(200) {
+		t.Errorf("Invalid log record: %v", record)
+	}
+	if !strings.Contains(record["url"].(string), "limit=10") {
+		t.Errorf("Unredacted query parameters should be logged: %v", record["url"])
+	}
+	if record["request_headers"].(map[string]any)["X-Test"] != "visible" {
+		t.Errorf("Unredacted headers should be logged: %v", record["request_headers"])
+	}
+	if _, ok := record["latency"]; !ok {
+		t.Error("Latency was not logged")
+	}
+}
+
+func TestLoggingRedactsForms(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token": "secret-access-token", "expires_in": 3600}`)
+	}))
+	defer fakeServer.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	client := &Client{
+		HTTPClient: &http.Client{},
+		Middleware: []Middleware{Logging(logger, LogOptions{
+			LogBodies:        true,
+			RedactBodyFields: []string{"refresh_token", "client_secret", "access_token"},
+		})},
+	}
+	// Token requests are sent with the configured client, middleware included.
+	auth := RefreshTokenGrant(OAuth2Config{
+		TokenURL:          fakeServer.URL,
+		ClientID:          "client",
+		ClientSecret:      "secret-client",
+		CredentialsInBody: true,
+		Client:            client,
+	}, "secret-refresh-token")
+	if _, err := auth.Token(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	out := buf.String()
+	for _, secret := range []string{"secret-client", "secret-refresh-token", "secret-access-token"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("Log leaked %s: %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "grant_type=refresh_token") || !strings.Contains(out, "client_id=client") {
+		t.Errorf("Unredacted form fields should be logged: %s", out)
+	}
+}
+
+func TestLoggingRequestSize(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer fakeServer.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	client := &Client{HTTPClient: &http.Client{}, Middleware: []Middleware{Logging(logger, LogOptions{})}}
+	request := Request{
+		Method:        Post,
+		BaseURL:       fakeServer.URL,
+		ContentLength: 4,
+		GetBody: func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("data")), nil
+		},
+	}
+	if _, err := client.Send(request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"request_size":4`) {
+		t.Errorf("Invalid request size: %s", buf.String())
+	}
+}
+
+func TestLoggingError(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	client := &Client{HTTPClient: &http.Client{}, Middleware: []Middleware{Logging(logger, LogOptions{})}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.SendWithContext(ctx, Request{Method: Get, BaseURL: "http://localhost"}); err == nil {
+		t.Fatal("Expected an error for a canceled context")
+	}
+	if !strings.Contains(buf.String(), `"level":"ERROR"`) || !strings.Contains(buf.String(), "context canceled") {
+		t.Errorf("Failed request was not logged as an error: %s", buf.String())
+	}
+}
+
+func TestLoggingRedactsErrors(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	client := &Client{
+		HTTPClient: &http.Client{},
+		Middleware: []Middleware{Logging(logger, LogOptions{RedactQueryParams: []string{"token"}})},
+	}
+
+	// A refused connection fails with a *url.Error.
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closed.Close()
+	if _, err := client.Send(Request{Method: Get, BaseURL: closed.URL + "?token=secret-token"}); err == nil {
+		t.Fatal("Expected an error for a closed server")
+	}
+
+	// A non-2xx response fails with a *RestError.
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer fakeServer.Close()
+	client.ErrorOnStatus = true
+	if _, err := client.Send(Request{Method: Get, BaseURL: fakeServer.URL + "?token=secret-token"}); !IsNotFound(err) {
+		t.Fatalf("Expected a 404 error, got %v", err)
+	}
+
+	out := buf.String()
+	if strings.Contains(out, "secret-token") {
+		t.Errorf("Error leaked a redacted query parameter: %s", out)
+	}
+	if strings.Count(out, `"error":`) != 2 {
+		t.Errorf("Expected 2 errors to be logged: %s", out)
+	}
+}
@@ -0,0 +1,223 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the latency histogram buckets, in seconds, used when
+// MetricsCollector.Buckets is empty.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// MetricsCollector is a Meter that counts requests and records their
+// latency by host, method, route and status class, and serves them in the
+// Prometheus text exposition format:
+//
+//	metrics := &rest.MetricsCollector{}
+//	client := &rest.Client{HTTPClient: &http.Client{}, Meter: metrics}
+//	http.Handle("/metrics", metrics)
+//
+// The route label is the path template of requests sent with PathParams,
+// and is empty for other requests, so raw URLs never become labels.
+// It is safe for concurrent use and the zero value is ready to use.
+type MetricsCollector struct {
+	// Namespace prefixes every metric name. Defaults to "rest_client".
+	Namespace string
+	// Buckets are the upper bounds of the latency histogram, in seconds,
+	// in increasing order. Defaults to DefaultBuckets. A histogram keeps
+	// the buckets it was created with, so changes only apply to new series.
+	Buckets []float64
+
+	mu        sync.Mutex
+	durations map[metricLabels]*histogram
+	inFlight  map[metricLabels]int64
+}
+
+type metricLabels struct {
+	host, method, route, statusClass string
+}
+
+type histogram struct {
+	bounds []float64
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// RecordDuration implements Meter.
+func (m *MetricsCollector) RecordDuration(ctx context.Context, d time.Duration, attrs map[string]interface{}) {
+	labels := labelsFromAttrs(attrs)
+	labels.statusClass = statusClass(attrs)
+	buckets := m.buckets()
+	seconds := d.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.durations == nil {
+		m.durations = make(map[metricLabels]*histogram)
+	}
+	h := m.durations[labels]
+	if h == nil {
+		h = &histogram{
+			bounds: append([]float64(nil), buckets...),
+			counts: make([]uint64, len(buckets)),
+		}
+		m.durations[labels] = h
+	}
+	for i, bound := range h.bounds {
+		if seconds <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// AddInFlight implements Meter.
+func (m *MetricsCollector) AddInFlight(ctx context.Context, delta int64, attrs map[string]interface{}) {
+	labels := labelsFromAttrs(attrs)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.inFlight == nil {
+		m.inFlight = make(map[metricLabels]int64)
+	}
+	m.inFlight[labels] += delta
+}
+
+// ServeHTTP writes the collected metrics in the Prometheus text format.
+func (m *MetricsCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(m.text()) // nolint
+}
+
+// text returns the collected metrics in the Prometheus text format.
+func (m *MetricsCollector) text() []byte {
+	namespace := m.Namespace
+	if namespace == "" {
+		namespace = "rest_client"
+	}
+	buf := &bytes.Buffer{}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	requests := namespace + "_requests_total"
+	fmt.Fprintf(buf, "# HELP %s Requests sent, by host, method, route and status class.\n", requests)
+	fmt.Fprintf(buf, "# TYPE %s counter\n", requests)
+	keys := sortedLabels(m.durations)
+	for _, labels := range keys {
+		fmt.Fprintf(buf, "%s{%s} %d\n", requests, labels.format(true), m.durations[labels].count)
+	}
+
+	duration := namespace + "_request_duration_seconds"
+	fmt.Fprintf(buf, "# HELP %s Request latency, including retries.\n", duration)
+	fmt.Fprintf(buf, "# TYPE %s histogram\n", duration)
+	for _, labels := range keys {
+		h := m.durations[labels]
+		prefix := labels.format(true)
+		var cumulative uint64
+		for i, bound := range h.bounds {
+			cumulative += h.counts[i]
+			fmt.Fprintf(buf, "%s_bucket{%s,le=%q} %d\n", duration, prefix, formatFloat(bound), cumulative)
+		}
+		fmt.Fprintf(buf, "%s_bucket{%s,le=\"+Inf\"} %d\n", duration, prefix, h.count)
+		fmt.Fprintf(buf, "%s_sum{%s} %s\n", duration, prefix, formatFloat(h.sum))
+		fmt.Fprintf(buf, "%s_count{%s} %d\n", duration, prefix, h.count)
+	}
+
+	inFlight := namespace + "_requests_in_flight"
+	fmt.Fprintf(buf, "# HELP %s Requests in progress, by host, method and route.\n", inFlight)
+	fmt.Fprintf(buf, "# TYPE %s gauge\n", inFlight)
+	for _, labels := range sortedLabels(m.inFlight) {
+		fmt.Fprintf(buf, "%s{%s} %d\n", inFlight, labels.format(false), m.inFlight[labels])
+	}
+	return buf.Bytes()
+}
+
+func (m *MetricsCollector) buckets() []float64 {
+	if len(m.Buckets) == 0 {
+		return DefaultBuckets
+	}
+	return m.Buckets
+}
+
+// labelsFromAttrs picks the low-cardinality labels out of Meter attributes.
+func labelsFromAttrs(attrs map[string]interface{}) metricLabels {
+	str := func(key string) string {
+		s, _ := attrs[key].(string)
+		return s
+	}
+	return metricLabels{
+		host:   str(AttrServerAddress),
+		method: str(AttrHTTPMethod),
+		route:  str(AttrHTTPRoute),
+	}
+}
+
+// statusClass returns "2xx", "4xx" and so on, or "error" for calls that
+// got no response.
+func statusClass(attrs map[string]interface{}) string {
+	code, ok := attrs[AttrHTTPStatusCode].(int)
+	if !ok || code < 100 {
+		return "error"
+	}
+	return strconv.Itoa(code/100) + "xx"
+}
+
+// sortedLabels returns the keys of a metric map in a stable order.
+func sortedLabels(m interface{}) []metricLabels {
+	var keys []metricLabels
+	switch m := m.(type) {
+	case map[metricLabels]*histogram:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[metricLabels]int64:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.host != b.host {
+			return a.host < b.host
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		return a.statusClass < b.statusClass
+	})
+	return keys
+}
+
+// format returns the labels as name="value" pairs.
+func (l metricLabels) format(withStatus bool) string {
+	s := fmt.Sprintf("host=%s,method=%s,route=%s",
+		quoteLabel(l.host), quoteLabel(l.method), quoteLabel(l.route))
+	if withStatus {
+		s += ",status_class=" + quoteLabel(l.statusClass)
+	}
+	return s
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quoteLabel(value string) string {
+	return `"` + labelEscaper.Replace(value) + `"`
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,102 @@
+package rest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestMetricsCollector(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer fakeServer.Close()
+
+	metrics := &MetricsCollector{Buckets: []float64{1, 10}}
+	client := &Client{HTTPClient: &http.Client{}, Meter: metrics}
+	for _, id := range []string{"a", "b", "missing"} {
+		_, err := client.Send(Request{
+			Method:     Get,
+			BaseURL:    fakeServer.URL + "/v3/items/{id}",
+			PathParams: map[string]string{"id": id},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(recorder.Body)
+	text := string(body)
+
+	labels := `host="127.0.0.1",method="GET",route="/v3/items/{id}"`
+	expected := []string{
+		"# TYPE rest_client_requests_total counter",
+		`rest_client_requests_total{` + labels + `,status_class="2xx"} 2`,
+		`rest_client_requests_total{` + labels + `,status_class="4xx"} 1`,
+		"# TYPE rest_client_request_duration_seconds histogram",
+		`rest_client_request_duration_seconds_bucket{` + labels + `,status_class="2xx",le="1"} 2`,
+		`rest_client_request_duration_seconds_bucket{` + labels + `,status_class="2xx",le="+Inf"} 2`,
+		`rest_client_request_duration_seconds_count{` + labels + `,status_class="4xx"} 1`,
+		`rest_client_requests_in_flight{` + labels + `} 0`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("Metrics are missing %q:\n%s", line, text)
+		}
+	}
+	if strings.Contains(text, "/v3/items/a") {
+		t.Error("Metrics should not contain raw URLs")
+	}
+}
+
+func TestMetricsCollectorBuckets(t *testing.T) {
+	t.Parallel()
+	metrics := &MetricsCollector{Namespace: "api", Buckets: []float64{0.1, 1}}
+	ctx := context.Background()
+	attrs := map[string]interface{}{AttrHTTPMethod: "POST", AttrServerAddress: "example.com"}
+	metrics.RecordDuration(ctx, 50*time.Millisecond, attrs)
+	metrics.RecordDuration(ctx, 500*time.Millisecond, attrs)
+	metrics.RecordDuration(ctx, 5*time.Second, attrs)
+
+	text := string(metrics.text())
+	labels := `host="example.com",method="POST",route="",status_class="error"`
+	expected := []string{
+		`api_request_duration_seconds_bucket{` + labels + `,le="0.1"} 1`,
+		`api_request_duration_seconds_bucket{` + labels + `,le="1"} 2`,
+		`api_request_duration_seconds_bucket{` + labels + `,le="+Inf"} 3`,
+		`api_request_duration_seconds_sum{` + labels + `} 5.55`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("Metrics are missing %q:\n%s", line, text)
+		}
+	}
+
+	// Existing histograms keep their buckets when Buckets changes.
+	metrics.Buckets = []float64{0.01, 0.1, 1, 10}
+	metrics.RecordDuration(ctx, 5*time.Millisecond, attrs)
+	text = string(metrics.text())
+	if !strings.Contains(text, `api_request_duration_seconds_bucket{`+labels+`,le="0.1"} 2`+"\n") {
+		t.Errorf("Invalid buckets after Buckets changed:\n%s", text)
+	}
+	if strings.Contains(text, `le="10"`) {
+		t.Errorf("An existing histogram should keep its buckets:\n%s", text)
+	}
+}
+
+func TestQuoteLabel(t *testing.T) {
+	t.Parallel()
+	if quoted := quoteLabel("a\"b\\c\nd"); quoted != `"a\"b\\c\nd"` {
+		t.Errorf("Label was not escaped: %s", quoted)
+	}
+}
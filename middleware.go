@@ -0,0 +1,34 @@
+package rest
+
+import "context"
+
+// Handler sends a Request and returns its Response.
+type Handler func(ctx context.Context, request Request) (*Response, error)
+
+// Middleware wraps a Handler to run code before the request is sent and
+// after its response is built, e.g.
+//
+//	func Timing(next rest.Handler) rest.Handler {
+//		return func(ctx context.Context, request rest.Request) (*rest.Response, error) {
+//			start := time.Now()
+//			response, err := next(ctx, request)
+//			log.Println(request.Method, request.BaseURL, time.Since(start))
+//			return response, err
+//		}
+//	}
+type Middleware func(next Handler) Handler
+
+// DefaultHeaders returns a Middleware that adds headers to every request
+// that does not already set them, whatever the case of their keys.
+func DefaultHeaders(headers map[string]string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, request Request) (*Response, error) {
+			for key, value := range headers {
+				if !hasHeader(request.Headers, key) {
+					request.setHeader(key, value)
+				}
+			}
+			return next(ctx, request)
+		}
+	}
+}
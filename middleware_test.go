@@ -0,0 +1,85 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Trace", r.Header.Get("X-Trace"))
+	}))
+	defer fakeServer.Close()
+
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, request Request) (*Response, error) {
+				calls = append(calls, name+" request")
+				request.setHeader("X-Trace", request.Headers["X-Trace"]+name)
+				response, err := next(ctx, request)
+				calls = append(calls, name+" response "+response.Headers["X-Trace"][0])
+				return response, err
+			}
+		}
+	}
+
+	client := &Client{HTTPClient: &http.Client{}, Middleware: []Middleware{record("a"), record("b")}}
+	if _, err := client.Send(Request{Method: Get, BaseURL: fakeServer.URL}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "a request, b request, b response ab, a response ab"
+	if strings.Join(calls, ", ") != expected {
+		t.Errorf("Middleware ran out of order: %s", strings.Join(calls, ", "))
+	}
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	t.Parallel()
+	cached := &Response{StatusCode: 200, Body: "cached"}
+	cache := func(next Handler) Handler {
+		return func(ctx context.Context, request Request) (*Response, error) {
+			return cached, nil
+		}
+	}
+	client := &Client{HTTPClient: &http.Client{}, Middleware: []Middleware{cache}}
+	response, err := client.Send(Request{Method: Get, BaseURL: "http://unreachable.invalid"})
+	if err != nil || response != cached {
+		t.Errorf("Middleware response was not returned: %v, %v", response, err)
+	}
+}
+
+func TestDefaultHeaders(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Default") != "1" || r.Header.Get("X-Override") != "request" {
+			t.Errorf("Invalid headers: %v", r.Header)
+		}
+	}))
+	defer fakeServer.Close()
+
+	client := &Client{
+		HTTPClient: &http.Client{},
+		Middleware: []Middleware{DefaultHeaders(map[string]string{"X-Default": "1", "X-Override": "default"})},
+	}
+	headers := map[string]string{"X-Override": "request"}
+	if _, err := client.Send(Request{Method: Get, BaseURL: fakeServer.URL, Headers: headers}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(headers) != 1 {
+		t.Error("DefaultHeaders should not modify the caller's headers map")
+	}
+
+	// Headers are set in map order, so repeat to catch duplicates.
+	headers = map[string]string{"x-override": "request"}
+	for i := 0; i < 10; i++ {
+		if _, err := client.Send(Request{Method: Get, BaseURL: fakeServer.URL, Headers: headers}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+}
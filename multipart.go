@@ -0,0 +1,199 @@
+package rest
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Multipart builds a multipart/form-data request body from fields and
+// files. Parts are streamed when the request is sent, so files are never
+// held in memory in full.
+type Multipart struct {
+	boundary string
+	parts    []multipartPart
+}
+
+type multipartPart struct {
+	field    string
+	filename string // empty for plain fields
+	data     []byte
+	path     string
+	reader   io.Reader
+	size     int64 // -1 if unknown
+}
+
+// NewMultipart returns an empty Multipart with a random boundary.
+func NewMultipart() *Multipart {
+	return &Multipart{boundary: multipart.NewWriter(ioutil.Discard).Boundary()}
+}
+
+// ContentType returns the Content-Type header value, including the boundary.
+func (m *Multipart) ContentType() string {
+	return "multipart/form-data; boundary=" + m.boundary
+}
+
+// AddField adds a form field.
+func (m *Multipart) AddField(field, value string) {
+	m.parts = append(m.parts, multipartPart{field: field, data: []byte(value), size: int64(len(value))})
+}
+
+// AddFileBytes adds a file whose contents are held in data.
+func (m *Multipart) AddFileBytes(field, filename string, data []byte) {
+	m.parts = append(m.parts, multipartPart{field: field, filename: filename, data: data, size: int64(len(data))})
+}
+
+// AddFilePath adds the file at path. The file is opened when the body is
+// streamed, and is named after the last element of path.
+func (m *Multipart) AddFilePath(field, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	m.parts = append(m.parts, multipartPart{field: field, filename: filepath.Base(path), path: path, size: info.Size()})
+	return nil
+}
+
+// AddFileReader adds a file read from r. A Multipart with a reader part
+// can only be sent once, so requests using it are not retried.
+func (m *Multipart) AddFileReader(field, filename string, r io.Reader) {
+	m.parts = append(m.parts, multipartPart{field: field, filename: filename, reader: r, size: -1})
+}
+
+// Reader returns a reader that streams the encoded body. Encoding starts
+// on the first call to Read.
+func (m *Multipart) Reader() io.ReadCloser {
+	return &multipartReader{m: m}
+}
+
+// Len returns the length of the encoded body, or -1 if it is unknown
+// because a part is read from an io.Reader.
+func (m *Multipart) Len() int64 {
+	counter := &countingWriter{}
+	w := multipart.NewWriter(counter)
+	w.SetBoundary(m.boundary) // nolint
+	for _, part := range m.parts {
+		if part.size < 0 {
+			return -1
+		}
+		if _, err := m.createPart(w, part); err != nil {
+			return -1
+		}
+		counter.n += part.size
+	}
+	if err := w.Close(); err != nil {
+		return -1
+	}
+	return counter.n
+}
+
+// rewindable reports whether the body can be encoded more than once.
+func (m *Multipart) rewindable() bool {
+	for _, part := range m.parts {
+		if part.reader != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Multipart) createPart(w *multipart.Writer, part multipartPart) (io.Writer, error) {
+	if part.filename == "" {
+		return w.CreateFormField(part.field)
+	}
+	return w.CreateFormFile(part.field, part.filename)
+}
+
+func (m *Multipart) writeTo(dst io.Writer) error {
+	w := multipart.NewWriter(dst)
+	if err := w.SetBoundary(m.boundary); err != nil {
+		return err
+	}
+	for _, part := range m.parts {
+		pw, err := m.createPart(w, part)
+		if err != nil {
+			return err
+		}
+		if err := copyPart(pw, part); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+func copyPart(dst io.Writer, part multipartPart) error {
+	switch {
+	case part.reader != nil:
+		_, err := io.Copy(dst, part.reader)
+		return err
+	case part.path != "":
+		f, err := os.Open(part.path)
+		if err != nil {
+			return err
+		}
+		defer f.Close() // nolint
+		_, err = io.Copy(dst, f)
+		return err
+	default:
+		_, err := io.Copy(dst, bytes.NewReader(part.data))
+		return err
+	}
+}
+
+// multipartReader encodes a Multipart through a pipe once it is first read.
+type multipartReader struct {
+	m    *Multipart
+	once sync.Once
+	pr   *io.PipeReader
+}
+
+func (r *multipartReader) start() {
+	r.once.Do(func() {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(r.m.writeTo(pw)) // nolint
+		}()
+		r.pr = pr
+	})
+}
+
+func (r *multipartReader) Read(p []byte) (int, error) {
+	r.start()
+	return r.pr.Read(p)
+}
+
+func (r *multipartReader) Close() error {
+	r.start()
+	return r.pr.Close()
+}
+
+// countingWriter counts the bytes written to it.
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+// SetMultipart makes m the body of the request and sets its Content-Type.
+func (r *Request) SetMultipart(m *Multipart) {
+	r.setHeader("Content-Type", m.ContentType())
+	r.Body = nil
+	r.BodyReader = m.Reader()
+	r.ContentLength = 0
+	if n := m.Len(); n > 0 {
+		r.ContentLength = n
+	}
+	r.GetBody = nil
+	if m.rewindable() {
+		r.GetBody = func() (io.ReadCloser, error) {
+			return m.Reader(), nil
+		}
+	}
+}
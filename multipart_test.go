@@ -0,0 +1,124 @@
+package rest
+
+import (
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMultipart(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "rest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "report.csv")
+	if err := ioutil.WriteFile(path, []byte("a,b,c\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var attempts int32
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType != "multipart/form-data" {
+			t.Errorf("Invalid Content-Type: %s", r.Header.Get("Content-Type"))
+		}
+		if r.ContentLength <= 0 {
+			t.Error("Content length should be known for fields, bytes and paths")
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("Invalid multipart body: %v", err)
+			return
+		}
+		if r.FormValue("subject") != "Hello World" {
+			t.Errorf("Invalid field: %q", r.FormValue("subject"))
+		}
+		for field, want := range map[string]string{"attachment": "a,b,c\n", "logo": "PNG"} {
+			f, header, err := r.FormFile(field)
+			if err != nil {
+				t.Errorf("Missing file %s: %v", field, err)
+				continue
+			}
+			data, _ := ioutil.ReadAll(f)
+			if string(data) != want {
+				t.Errorf("Invalid file %s (%s): %q", field, header.Filename, data)
+			}
+		}
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer fakeServer.Close()
+
+	m := NewMultipart()
+	m.AddField("subject", "Hello World")
+	if err := m.AddFilePath("attachment", path); err != nil {
+		t.Fatal(err)
+	}
+	m.AddFileBytes("logo", "logo.png", []byte("PNG"))
+
+	shared := map[string]string{"Authorization": "Bearer API_KEY"}
+	request := Request{Method: Post, BaseURL: fakeServer.URL, Headers: shared}
+	request.SetMultipart(m)
+	if _, ok := shared["Content-Type"]; ok {
+		t.Error("SetMultipart should not modify the caller's headers map")
+	}
+
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	client := &Client{HTTPClient: &http.Client{}, RetryPolicy: policy}
+	response, err := client.Send(request)
+	if err != nil || response.StatusCode != 200 {
+		t.Fatalf("Expected a successful retry, got %v, %v", response, err)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts)
+	}
+
+	// The same request can be sent again.
+	response, err = client.Send(request)
+	if err != nil || response.StatusCode != 200 {
+		t.Fatalf("Expected the request to be resent, got %v, %v", response, err)
+	}
+}
+
+func TestMultipartReader(t *testing.T) {
+	t.Parallel()
+	m := NewMultipart()
+	m.AddFileReader("file", "data.txt", strings.NewReader("streamed"))
+	if m.Len() != -1 {
+		t.Error("Length should be unknown for reader parts")
+	}
+
+	request := Request{Method: Post, BaseURL: "http://localhost"}
+	request.SetMultipart(m)
+	if request.GetBody != nil {
+		t.Error("A body with reader parts cannot be recreated")
+	}
+	body, err := ioutil.ReadAll(request.BodyReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), "streamed") || !strings.Contains(string(body), `filename="data.txt"`) {
+		t.Errorf("Invalid multipart body: %s", body)
+	}
+}
+
+func TestMultipartLen(t *testing.T) {
+	t.Parallel()
+	m := NewMultipart()
+	m.AddField("name", "value")
+	m.AddFileBytes("file", "f.bin", []byte{1, 2, 3})
+	body, _ := ioutil.ReadAll(m.Reader())
+	if int64(len(body)) != m.Len() {
+		t.Errorf("Len() = %d, encoded body is %d bytes", m.Len(), len(body))
+	}
+}
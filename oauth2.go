@@ -0,0 +1,139 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// OAuth2Config describes an OAuth2 client and its token endpoint.
+type OAuth2Config struct {
+	TokenURL     string   // e.g. https://auth.example.com/oauth2/token
+	ClientID     string   //
+	ClientSecret string   //
+	Scopes       []string // optional
+	// CredentialsInBody sends the client ID and secret as form fields
+	// instead of HTTP basic auth, for servers that require it.
+	CredentialsInBody bool
+	// Client sends the token requests. It uses DefaultClient if nil.
+	// Its Authenticator is not used, so it may be the client the tokens
+	// are for.
+	Client *Client
+}
+
+// oauth2Token is the token endpoint response from RFC 6749 section 5.1.
+type oauth2Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// oauth2Error is the token endpoint error from RFC 6749 section 5.2.
+type oauth2Error struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+	URI         string `json:"error_uri"`
+}
+
+// ClientCredentials returns an Authenticator that obtains tokens with the
+// OAuth2 client credentials grant.
+func ClientCredentials(config OAuth2Config) *RefreshableToken {
+	return &RefreshableToken{Source: func(ctx context.Context) (*Token, error) {
+		values := url.Values{"grant_type": {"client_credentials"}}
+		token, err := config.requestToken(ctx, values)
+		if err != nil {
+			return nil, err
+		}
+		return token.token(), nil
+	}}
+}
+
+// RefreshTokenGrant returns an Authenticator that obtains access tokens
+// with the OAuth2 refresh token grant. If the server rotates the refresh
+// token, the new one is used for the next grant.
+func RefreshTokenGrant(config OAuth2Config, refreshToken string) *RefreshableToken {
+	// The RefreshableToken serializes calls to Source, so refreshToken
+	// needs no further locking.
+	return &RefreshableToken{Source: func(ctx context.Context) (*Token, error) {
+		values := url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {refreshToken},
+		}
+		token, err := config.requestToken(ctx, values)
+		if err != nil {
+			return nil, err
+		}
+		if token.RefreshToken != "" {
+			refreshToken = token.RefreshToken
+		}
+		return token.token(), nil
+	}}
+}
+
+// requestToken sends a grant to the token endpoint.
+func (c OAuth2Config) requestToken(ctx context.Context, values url.Values) (*oauth2Token, error) {
+	if len(c.Scopes) > 0 {
+		values.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	request := Request{
+		Method:  Post,
+		BaseURL: c.TokenURL,
+		Headers: map[string]string{"Accept": "application/json"},
+	}
+	if c.CredentialsInBody {
+		values.Set("client_id", c.ClientID)
+		values.Set("client_secret", c.ClientSecret)
+	} else {
+		// RFC 6749 section 2.3.1 form-encodes the credentials first.
+		request.Headers["Authorization"] = "Basic " + basicCredentials(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+	}
+	request.SetForm(values)
+
+	client := c.Client
+	if client == nil {
+		client = DefaultClient
+	}
+	// Token requests carry their own credentials. Authenticating them too
+	// would deadlock when the client's Authenticator is this token.
+	tokenClient := *client
+	tokenClient.Authenticator = nil
+	response, err := tokenClient.SendWithContext(ctx, request)
+	if err != nil {
+		// A client with ErrorOnStatus returns the error response too.
+		var restErr *RestError
+		if !errors.As(err, &restErr) || restErr.Response == nil {
+			return nil, err
+		}
+		response = restErr.Response
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		restErr := newRestError(request, response)
+		var oauthErr oauth2Error
+		if json.Unmarshal([]byte(response.Body), &oauthErr) == nil && oauthErr.Error != "" {
+			restErr.Problem = &Problem{Type: oauthErr.URI, Title: oauthErr.Error, Detail: oauthErr.Description}
+		}
+		return nil, restErr
+	}
+
+	var token oauth2Token
+	if err := json.Unmarshal([]byte(response.Body), &token); err != nil {
+		return nil, fmt.Errorf("rest: invalid OAuth2 token response: %v", err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("rest: OAuth2 token response has no access_token")
+	}
+	return &token, nil
+}
+
+func (t *oauth2Token) token() *Token {
+	token := &Token{AccessToken: t.AccessToken}
+	if t.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+	}
+	return token
+}
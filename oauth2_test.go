@@ -0,0 +1,173 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// newTokenServer returns a token endpoint that issues "token-N" access
+// tokens and rotating "refresh-N" refresh tokens.
+func newTokenServer(t *testing.T, fetches *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("Invalid token request: %v", err)
+		}
+		user, pass, _ := r.BasicAuth()
+		if r.PostForm.Get("client_id") != "" {
+			user, pass = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+		}
+		if user != "client" || pass != "secret" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error": "invalid_client", "error_description": "bad credentials"}`)
+			return
+		}
+		n := atomic.AddInt32(fetches, 1)
+		switch r.PostForm.Get("grant_type") {
+		case "client_credentials":
+			if r.PostForm.Get("scope") != "mail.send alerts.read" {
+				t.Errorf("Invalid scope: %q", r.PostForm.Get("scope"))
+			}
+		case "refresh_token":
+			if want := fmt.Sprintf("refresh-%d", n-1); r.PostForm.Get("refresh_token") != want {
+				t.Errorf("Refresh token was not rotated: got %q, want %q", r.PostForm.Get("refresh_token"), want)
+			}
+		default:
+			t.Errorf("Invalid grant type: %q", r.PostForm.Get("grant_type"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "Bearer", "expires_in": 3600, "refresh_token": "refresh-%d"}`, n, n)
+	}))
+}
+
+// newProtectedServer returns an API that accepts only the given token.
+func newProtectedServer(token string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+}
+
+func TestClientCredentials(t *testing.T) {
+	t.Parallel()
+	var fetches int32
+	tokenServer := newTokenServer(t, &fetches)
+	defer tokenServer.Close()
+	api := newProtectedServer("token-1")
+	defer api.Close()
+
+	auth := ClientCredentials(OAuth2Config{
+		TokenURL:     tokenServer.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Scopes:       []string{"mail.send", "alerts.read"},
+	})
+	client := &Client{HTTPClient: &http.Client{}, Authenticator: auth}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := client.Send(Request{Method: Get, BaseURL: api.URL})
+			if err != nil || response.StatusCode != 200 {
+				t.Errorf("Request was not authenticated: %v, %v", response, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if fetches != 1 {
+		t.Errorf("Concurrent requests should share one token, got %d fetches", fetches)
+	}
+}
+
+func TestRefreshTokenGrant(t *testing.T) {
+	t.Parallel()
+	var fetches int32
+	tokenServer := newTokenServer(t, &fetches)
+	defer tokenServer.Close()
+	// Only the second token is accepted, so the first request gets a 401
+	// and is resent after a refresh.
+	api := newProtectedServer("token-2")
+	defer api.Close()
+
+	auth := RefreshTokenGrant(OAuth2Config{
+		TokenURL:          tokenServer.URL,
+		ClientID:          "client",
+		ClientSecret:      "secret",
+		CredentialsInBody: true,
+	}, "refresh-0")
+	client := &Client{HTTPClient: &http.Client{}, Authenticator: auth}
+	response, err := client.Send(Request{Method: Get, BaseURL: api.URL})
+	if err != nil || response.StatusCode != 200 {
+		t.Fatalf("Request was not resent after a 401: %v, %v", response, err)
+	}
+	if fetches != 2 {
+		t.Errorf("Expected 2 token fetches, got %d", fetches)
+	}
+}
+
+func TestOAuth2Error(t *testing.T) {
+	t.Parallel()
+	var fetches int32
+	tokenServer := newTokenServer(t, &fetches)
+	defer tokenServer.Close()
+
+	auth := ClientCredentials(OAuth2Config{TokenURL: tokenServer.URL, ClientID: "client", ClientSecret: "wrong"})
+	_, err := auth.Token(context.Background())
+	if !IsUnauthorized(err) {
+		t.Fatalf("Expected a 401 RestError, got %v", err)
+	}
+	restErr := err.(*RestError)
+	if restErr.Problem == nil || restErr.Problem.Title != "invalid_client" || restErr.Problem.Detail != "bad credentials" {
+		t.Errorf("OAuth2 error was not decoded: %+v", restErr.Problem)
+	}
+}
+
+func TestOAuth2ErrorOnStatus(t *testing.T) {
+	t.Parallel()
+	var fetches int32
+	tokenServer := newTokenServer(t, &fetches)
+	defer tokenServer.Close()
+
+	client := &Client{HTTPClient: &http.Client{}, ErrorOnStatus: true}
+	auth := ClientCredentials(OAuth2Config{TokenURL: tokenServer.URL, ClientID: "client", ClientSecret: "wrong", Client: client})
+	_, err := auth.Token(context.Background())
+	restErr, ok := err.(*RestError)
+	if !ok || restErr.Problem == nil || restErr.Problem.Title != "invalid_client" {
+		t.Errorf("OAuth2 error was not decoded with ErrorOnStatus: %v", err)
+	}
+}
+
+func TestOAuth2SelfReference(t *testing.T) {
+	t.Parallel()
+	var fetches int32
+	tokenServer := newTokenServer(t, &fetches)
+	defer tokenServer.Close()
+	api := newProtectedServer("token-1")
+	defer api.Close()
+
+	// The client fetches its own tokens.
+	client := &Client{HTTPClient: &http.Client{}}
+	client.Authenticator = ClientCredentials(OAuth2Config{
+		TokenURL:     tokenServer.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Scopes:       []string{"mail.send", "alerts.read"},
+		Client:       client,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	response, err := client.SendWithContext(ctx, Request{Method: Get, BaseURL: api.URL})
+	if err != nil || response.StatusCode != 200 {
+		t.Errorf("Request with a self-referencing token client failed: %v, %v", response, err)
+	}
+}
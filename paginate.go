@@ -0,0 +1,318 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Pagination decides which request fetches the page after a response.
+type Pagination interface {
+	// NextRequest returns the request for the page after response, which
+	// was returned for request, or nil if response is the last page.
+	NextRequest(request Request, response *Response) (*Request, error)
+}
+
+// Paginator fetches the pages of a list endpoint one at a time:
+//
+//	p := &rest.Paginator{Request: request, Pagination: rest.LinkHeader{}}
+//	for p.Next(ctx) {
+//		fmt.Println(p.Page().Body)
+//	}
+//	if err := p.Err(); err != nil {
+//		return err
+//	}
+type Paginator struct {
+	Client     *Client    // uses DefaultClient if nil
+	Request    Request    // fetches the first page
+	Pagination Pagination // finds the following pages
+	MaxPages   int        // stops after this many pages if positive
+
+	next  *Request
+	page  *Response
+	pages int
+	err   error
+}
+
+// Next fetches the next page, returning false when there are no more pages
+// or an error occurred. ctx only applies to this page's request.
+func (p *Paginator) Next(ctx context.Context) bool {
+	if p.err != nil || (p.MaxPages > 0 && p.pages >= p.MaxPages) {
+		return false
+	}
+	if p.pages == 0 {
+		p.next = &p.Request
+	}
+	if p.next == nil {
+		return false
+	}
+	if err := ctx.Err(); err != nil {
+		p.err = err
+		return false
+	}
+
+	client := p.Client
+	if client == nil {
+		client = DefaultClient
+	}
+	request := *p.next
+	response, err := client.SendWithContext(ctx, request)
+	if err == nil && (response.StatusCode < 200 || response.StatusCode > 299) {
+		err = newRestError(request, response)
+	}
+	if err != nil {
+		p.err = err
+		return false
+	}
+	p.page = response
+	p.pages++
+	p.next, p.err = p.Pagination.NextRequest(request, response)
+	return p.err == nil
+}
+
+// Page returns the page fetched by the last call to Next.
+func (p *Paginator) Page() *Response {
+	return p.page
+}
+
+// Err returns the error that stopped Next, if any.
+func (p *Paginator) Err() error {
+	return p.err
+}
+
+// LinkHeader follows the rel="next" link of an RFC 5988 Link header.
+type LinkHeader struct{}
+
+// NextRequest requests the URL of the next link, if there is one.
+func (LinkHeader) NextRequest(request Request, response *Response) (*Request, error) {
+	next := nextLink(http.Header(response.Headers).Values("Link"))
+	if next == "" {
+		return nil, nil
+	}
+	base, err := url.Parse(requestURL(request))
+	if err != nil {
+		return nil, err
+	}
+	ref, err := url.Parse(next)
+	if err != nil {
+		return nil, err
+	}
+	// The link carries the full query, so it replaces the request's.
+	request.BaseURL = base.ResolveReference(ref).String()
+	request.PathParams = nil
+	request.QueryParams = nil
+	request.Query = nil
+	return &request, nil
+}
+
+// nextLink returns the target of the rel="next" link in Link header values.
+// Targets are read before splitting links on commas, since URLs may
+// contain them.
+func nextLink(values []string) string {
+	for _, value := range values {
+		for {
+			start := strings.IndexByte(value, '<')
+			if start < 0 {
+				break
+			}
+			end := strings.IndexByte(value[start:], '>')
+			if end < 0 {
+				break
+			}
+			target := value[start+1 : start+end]
+			var params string
+			params, value = splitLinkParams(value[start+end+1:])
+			for _, param := range strings.Split(params, ";") {
+				param = strings.TrimSpace(param)
+				if !strings.HasPrefix(param, "rel=") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(param[len("rel="):], `"`)) {
+					if rel == "next" {
+						return target
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// splitLinkParams splits the parameters of a link from the links after it
+// at the first comma outside a quoted string.
+func splitLinkParams(s string) (params, rest string) {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				return s[:i], s[i+1:]
+			}
+		}
+	}
+	return s, ""
+}
+
+// OffsetLimit pages by advancing an offset query parameter by the page
+// size, stopping at the first page with fewer than Limit items.
+type OffsetLimit struct {
+	OffsetParam string // defaults to "offset"
+	LimitParam  string // defaults to "limit"
+	Limit       int    // page size
+	// Count returns the number of items in a page. Defaults to
+	// CountJSONArray.
+	Count func(response *Response) (int, error)
+}
+
+// NextRequest requests the page after response.
+func (o OffsetLimit) NextRequest(request Request, response *Response) (*Request, error) {
+	if o.Limit <= 0 {
+		return nil, errors.New("rest: OffsetLimit requires a positive Limit")
+	}
+	n, err := countItems(o.Count, response)
+	if err != nil || n < o.Limit {
+		return nil, err
+	}
+	query, err := urlQuery(request)
+	if err != nil {
+		return nil, err
+	}
+	offsetParam := paramOrDefault(o.OffsetParam, "offset")
+	offset, _ := strconv.Atoi(query.Get(offsetParam))
+	return withQuery(request, Query{
+		{Key: paramOrDefault(o.LimitParam, "limit"), Value: strconv.Itoa(o.Limit)},
+		{Key: offsetParam, Value: strconv.Itoa(offset + n)},
+	})
+}
+
+// PageNumber pages by incrementing a page number query parameter, stopping
+// at the first empty page.
+type PageNumber struct {
+	PageParam string // defaults to "page"
+	FirstPage int    // the number of the first page, usually 0 or 1
+	// Count returns the number of items in a page. Defaults to
+	// CountJSONArray.
+	Count func(response *Response) (int, error)
+}
+
+// NextRequest requests the page after response.
+func (p PageNumber) NextRequest(request Request, response *Response) (*Request, error) {
+	n, err := countItems(p.Count, response)
+	if err != nil || n == 0 {
+		return nil, err
+	}
+	query, err := urlQuery(request)
+	if err != nil {
+		return nil, err
+	}
+	pageParam := paramOrDefault(p.PageParam, "page")
+	page := p.FirstPage
+	if values, ok := query[pageParam]; ok {
+		if page, err = strconv.Atoi(values[0]); err != nil {
+			return nil, err
+		}
+	}
+	return withQuery(request, Query{{Key: pageParam, Value: strconv.Itoa(page + 1)}})
+}
+
+// Cursor pages by sending the opaque cursor found in each page as a query
+// parameter, stopping when a page has no cursor.
+type Cursor struct {
+	Param string // query parameter, e.g. "page_token"
+	// Next returns the cursor of the following page, or "" if there is none.
+	Next func(response *Response) (string, error)
+}
+
+// NextRequest requests the page after response.
+func (c Cursor) NextRequest(request Request, response *Response) (*Request, error) {
+	cursor, err := c.Next(response)
+	if err != nil || cursor == "" {
+		return nil, err
+	}
+	return withQuery(request, Query{{Key: c.Param, Value: cursor}})
+}
+
+// JSONField returns a function reading the string at a path of object
+// keys in a JSON body, e.g. JSONField("_metadata", "next_cursor"). It
+// returns "" if the field is missing or null.
+func JSONField(path ...string) func(response *Response) (string, error) {
+	return func(response *Response) (string, error) {
+		var v interface{}
+		if err := json.Unmarshal([]byte(response.Body), &v); err != nil {
+			return "", err
+		}
+		for _, key := range path {
+			object, ok := v.(map[string]interface{})
+			if !ok {
+				return "", nil
+			}
+			v = object[key]
+		}
+		s, _ := v.(string)
+		return s, nil
+	}
+}
+
+// CountJSONArray counts the elements of a body that is a JSON array.
+func CountJSONArray(response *Response) (int, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal([]byte(response.Body), &items); err != nil {
+		return 0, err
+	}
+	return len(items), nil
+}
+
+func countItems(count func(*Response) (int, error), response *Response) (int, error) {
+	if count == nil {
+		count = CountJSONArray
+	}
+	return count(response)
+}
+
+// urlQuery returns the query of the URL request is sent to, wherever its
+// parameters were set.
+func urlQuery(request Request) (url.Values, error) {
+	u, err := url.Parse(requestURL(request))
+	if err != nil {
+		return nil, err
+	}
+	return u.Query(), nil
+}
+
+// withQuery returns request with params set in the query of the URL it is
+// sent to, replacing their values in BaseURL, QueryParams and Query. The
+// path of BaseURL, and its template, are kept.
+func withQuery(request Request, params Query) (*Request, error) {
+	u, err := url.Parse(requestURL(request))
+	if err != nil {
+		return nil, err
+	}
+	query, err := mergeQuery(u.RawQuery, params, QueryReplace)
+	if err != nil {
+		return nil, err
+	}
+	base, fragment := request.BaseURL, ""
+	if i := strings.IndexByte(base, '#'); i >= 0 {
+		base, fragment = base[:i], base[i:]
+	}
+	if i := strings.IndexByte(base, '?'); i >= 0 {
+		base = base[:i]
+	}
+	request.BaseURL = base + "?" + query + fragment
+	request.QueryParams = nil
+	request.Query = nil
+	return &request, nil
+}
+
+func paramOrDefault(param, fallback string) string {
+	if param == "" {
+		return fallback
+	}
+	return param
+}
@@ -0,0 +1,24 @@
+//go:build go1.23
+// +build go1.23
+
+package rest
+
+import (
+	"context"
+	"iter"
+)
+
+// All returns an iterator over the remaining pages. Iteration stops at the
+// first error, which is yielded with a nil page.
+func (p *Paginator) All(ctx context.Context) iter.Seq2[*Response, error] {
+	return func(yield func(*Response, error) bool) {
+		for p.Next(ctx) {
+			if !yield(p.Page(), nil) {
+				return
+			}
+		}
+		if err := p.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+//go:build go1.23
+// +build go1.23
+
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPaginatorAll(t *testing.T) {
+	t.Parallel()
+	fakeServer := newItemsServer()
+	defer fakeServer.Close()
+
+	p := &Paginator{
+		Request:    Request{Method: Get, BaseURL: fakeServer.URL},
+		Pagination: OffsetLimit{Limit: 3},
+	}
+	var bodies []string
+	for page, err := range p.All(context.Background()) {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		bodies = append(bodies, page.Body)
+	}
+	if len(bodies) != 3 {
+		t.Errorf("Expected 3 pages, got %v", bodies)
+	}
+}
+
+func TestPaginatorAllError(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer fakeServer.Close()
+
+	p := &Paginator{Request: Request{Method: Get, BaseURL: fakeServer.URL}, Pagination: LinkHeader{}}
+	for page, err := range p.All(context.Background()) {
+		if page != nil || !IsNotFound(err) {
+			t.Errorf("Expected a 404 error, got %v, %v", page, err)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+// newItemsServer serves 7 items as JSON arrays, paged by the "offset" and
+// "limit" or "page" query parameters.
+func newItemsServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, values := range r.URL.Query() {
+			if len(values) > 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit == 0 {
+			limit = 3
+		}
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		if page := r.URL.Query().Get("page"); page != "" {
+			n, _ := strconv.Atoi(page)
+			offset = (n - 1) * limit
+		}
+		items := []string{}
+		for i := offset; i < offset+limit && i < 7; i++ {
+			items = append(items, strconv.Itoa(i))
+		}
+		fmt.Fprintf(w, "[%s]", strings.Join(items, ","))
+	}))
+}
+
+func collectPages(t *testing.T, p *Paginator) []string {
+	var pages []string
+	for p.Next(context.Background()) {
+		pages = append(pages, p.Page().Body)
+	}
+	if err := p.Err(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return pages
+}
+
+func TestPaginatorOffsetLimit(t *testing.T) {
+	t.Parallel()
+	fakeServer := newItemsServer()
+	defer fakeServer.Close()
+
+	p := &Paginator{
+		Request:    Request{Method: Get, BaseURL: fakeServer.URL, QueryParams: map[string]string{"limit": "3", "offset": "0"}},
+		Pagination: OffsetLimit{Limit: 3},
+	}
+	pages := collectPages(t, p)
+	if strings.Join(pages, " ") != "[0,1,2] [3,4,5] [6]" {
+		t.Errorf("Invalid pages: %v", pages)
+	}
+}
+
+func TestPaginatorPageNumber(t *testing.T) {
+	t.Parallel()
+	fakeServer := newItemsServer()
+	defer fakeServer.Close()
+
+	p := &Paginator{
+		Request:    Request{Method: Get, BaseURL: fakeServer.URL},
+		Pagination: PageNumber{FirstPage: 1},
+	}
+	pages := collectPages(t, p)
+	if strings.Join(pages, " ") != "[0,1,2] [3,4,5] [6] []" {
+		t.Errorf("Invalid pages: %v", pages)
+	}
+}
+
+func TestPaginatorStartingQuery(t *testing.T) {
+	t.Parallel()
+	fakeServer := newItemsServer()
+	defer fakeServer.Close()
+
+	tests := []struct {
+		request    Request
+		pagination Pagination
+		expected   string
+	}{
+		{Request{BaseURL: fakeServer.URL + "?offset=3&limit=3"}, OffsetLimit{Limit: 3}, "[3,4,5] [6]"},
+		{Request{BaseURL: fakeServer.URL, Query: Query{{Key: "limit", Value: "2"}, {Key: "offset", Value: "4"}}}, OffsetLimit{Limit: 2}, "[4,5] [6]"},
+		{Request{BaseURL: fakeServer.URL + "?page=2"}, PageNumber{FirstPage: 1}, "[3,4,5] [6] []"},
+		{Request{BaseURL: fakeServer.URL, Query: Query{{Key: "page", Value: "3"}}}, PageNumber{FirstPage: 1}, "[6] []"},
+	}
+	for _, test := range tests {
+		test.request.Method = Get
+		p := &Paginator{Request: test.request, Pagination: test.pagination}
+		if pages := strings.Join(collectPages(t, p), " "); pages != test.expected {
+			t.Errorf("%s %v: pages = %s, want %s", test.request.BaseURL, test.request.Query, pages, test.expected)
+		}
+	}
+}
+
+func TestPaginatorMaxPages(t *testing.T) {
+	t.Parallel()
+	fakeServer := newItemsServer()
+	defer fakeServer.Close()
+
+	p := &Paginator{
+		Request:    Request{Method: Get, BaseURL: fakeServer.URL},
+		Pagination: OffsetLimit{Limit: 3},
+		MaxPages:   2,
+	}
+	if pages := collectPages(t, p); len(pages) != 2 {
+		t.Errorf("Expected 2 pages, got %v", pages)
+	}
+}
+
+func TestPaginatorLinkHeader(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 2 {
+			w.Header().Set("Link", fmt.Sprintf(`</items?page=%d>; rel="next", </items?page=2>; rel="last"`, page+1))
+		} else {
+			w.Header().Set("Link", `</items?page=0>; rel="first prev"`)
+		}
+		fmt.Fprintf(w, "page %d", page)
+	}))
+	defer fakeServer.Close()
+
+	p := &Paginator{
+		Request:    Request{Method: Get, BaseURL: fakeServer.URL + "/items", QueryParams: map[string]string{"page": "0"}},
+		Pagination: LinkHeader{},
+	}
+	pages := collectPages(t, p)
+	if strings.Join(pages, ", ") != "page 0, page 1, page 2" {
+		t.Errorf("Invalid pages: %v", pages)
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		values   []string
+		expected string
+	}{
+		{[]string{`<https://api/items?page=2>; rel="next"`}, "https://api/items?page=2"},
+		{[]string{`<https://api/items?fields=id,name&page=2>; rel="next"`}, "https://api/items?fields=id,name&page=2"},
+		{[]string{`</a,b>; rel="last", </c,d>; rel=next`}, "/c,d"},
+		{[]string{`</a>; title="x, y"; rel="prev", </b>; rel="prev next"`}, "/b"},
+		{[]string{`</a>; rel="prev"`, `</b>; rel="next"`}, "/b"},
+		{[]string{`</a>; rel="last"`}, ""},
+		{nil, ""},
+	}
+	for _, test := range tests {
+		if got := nextLink(test.values); got != test.expected {
+			t.Errorf("nextLink(%q) = %q, want %q", test.values, got, test.expected)
+		}
+	}
+}
+
+func TestPaginatorCursor(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("page_token") {
+		case "":
+			fmt.Fprint(w, `{"result": [1], "_metadata": {"next_cursor": "abc"}}`)
+		case "abc":
+			fmt.Fprint(w, `{"result": [2], "_metadata": {"next_cursor": null}}`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer fakeServer.Close()
+
+	p := &Paginator{
+		Request:    Request{Method: Get, BaseURL: fakeServer.URL},
+		Pagination: Cursor{Param: "page_token", Next: JSONField("_metadata", "next_cursor")},
+	}
+	if pages := collectPages(t, p); len(pages) != 2 {
+		t.Errorf("Expected 2 pages, got %v", pages)
+	}
+}
+
+func TestPaginatorError(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer fakeServer.Close()
+
+	p := &Paginator{Request: Request{Method: Get, BaseURL: fakeServer.URL}, Pagination: LinkHeader{}}
+	if p.Next(context.Background()) {
+		t.Error("Next should fail on a 500 page")
+	}
+	if !IsServerError(p.Err()) {
+		t.Errorf("Expected a server error, got %v", p.Err())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p = &Paginator{Request: Request{Method: Get, BaseURL: fakeServer.URL}, Pagination: LinkHeader{}}
+	if p.Next(ctx) || p.Err() != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", p.Err())
+	}
+}
@@ -0,0 +1,164 @@
+package rest
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Query holds query parameters in the order they were added. Unlike
+// Request.QueryParams, a key may be repeated, e.g. ?id=1&id=2.
+type Query []QueryParam
+
+// QueryParam is a single query parameter.
+type QueryParam struct {
+	Key   string
+	Value string
+}
+
+// Add appends a parameter for every value given.
+func (q *Query) Add(key string, values ...string) {
+	for _, value := range values {
+		*q = append(*q, QueryParam{Key: key, Value: value})
+	}
+}
+
+// Get returns the first value for key, or "" if there is none.
+func (q Query) Get(key string) string {
+	for _, p := range q {
+		if p.Key == key {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+// Encode encodes the query in "key=value" form, keeping its order.
+func (q Query) Encode() string {
+	var b strings.Builder
+	for i, p := range q {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+		b.WriteString(url.QueryEscape(p.Key))
+		b.WriteByte('=')
+		b.WriteString(url.QueryEscape(p.Value))
+	}
+	return b.String()
+}
+
+// QueryConflict decides what happens when a request's query parameters use
+// a key that is already in the query string of its BaseURL.
+type QueryConflict int
+
+// Supported query conflict policies.
+const (
+	QueryReplace QueryConflict = iota // the request's parameters replace the URL's
+	QueryKeep                         // the URL's parameters are kept, the request's dropped
+	QueryAppend                       // both are sent
+)
+
+// queryFromMap converts a QueryParams map to a Query sorted by key.
+func queryFromMap(params map[string]string) Query {
+	values := make(url.Values, len(params))
+	for key, value := range params {
+		values.Set(key, value)
+	}
+	return QueryFromValues(values)
+}
+
+// parseQuery parses a raw query string, keeping its order.
+func parseQuery(raw string) (Query, error) {
+	var q Query
+	for _, pair := range strings.Split(raw, "&") {
+		if pair == "" {
+			continue
+		}
+		key, value := pair, ""
+		if i := strings.Index(pair, "="); i >= 0 {
+			key, value = pair[:i], pair[i+1:]
+		}
+		key, err := url.QueryUnescape(key)
+		if err != nil {
+			return nil, err
+		}
+		value, err = url.QueryUnescape(value)
+		if err != nil {
+			return nil, err
+		}
+		q.Add(key, value)
+	}
+	return q, nil
+}
+
+// mergeQuery adds params to the raw query string base, resolving repeated
+// keys with conflict. The pairs of base are kept exactly as written, so
+// valueless keys and escaping, which may be signed, are left untouched.
+func mergeQuery(base string, params Query, conflict QueryConflict) (string, error) {
+	inParams := make(map[string]bool, len(params))
+	for _, p := range params {
+		inParams[p.Key] = true
+	}
+
+	var pairs []string
+	inBase := make(map[string]bool)
+	for _, pair := range strings.Split(base, "&") {
+		if pair == "" {
+			continue
+		}
+		key, value := pair, ""
+		if i := strings.Index(pair, "="); i >= 0 {
+			key, value = pair[:i], pair[i+1:]
+		}
+		key, err := url.QueryUnescape(key)
+		if err != nil {
+			return "", err
+		}
+		if _, err := url.QueryUnescape(value); err != nil {
+			return "", err
+		}
+		inBase[key] = true
+		if conflict == QueryReplace && inParams[key] {
+			continue
+		}
+		pairs = append(pairs, pair)
+	}
+
+	var added Query
+	for _, p := range params {
+		if conflict == QueryKeep && inBase[p.Key] {
+			continue
+		}
+		added = append(added, p)
+	}
+	if len(added) > 0 {
+		pairs = append(pairs, added.Encode())
+	}
+	return strings.Join(pairs, "&"), nil
+}
+
+// QueryFromValues converts url.Values to a Query sorted by key, matching
+// the order of url.Values.Encode.
+func QueryFromValues(values url.Values) Query {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var q Query
+	for _, key := range keys {
+		q.Add(key, values[key]...)
+	}
+	return q
+}
+
+// EncodeQuery converts a struct, or a pointer to one, into a Query using
+// the "url" struct tag, in field order. Tags follow the rules of EncodeForm.
+func EncodeQuery(v interface{}) (Query, error) {
+	var q Query
+	if err := encodeValues(v, "url", func(key, value string) { q.Add(key, value) }); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
@@ -0,0 +1,131 @@
+package rest
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	t.Parallel()
+	var q Query
+	q.Add("z", "1")
+	q.Add("id", "1", "2")
+	q.Add("name", "a b&c")
+	if q.Encode() != "z=1&id=1&id=2&name=a+b%26c" {
+		t.Errorf("Query order was not preserved: %s", q.Encode())
+	}
+	if q.Get("id") != "1" || q.Get("missing") != "" {
+		t.Error("Invalid Query.Get result")
+	}
+
+	q = QueryFromValues(url.Values{"b": {"2"}, "a": {"1", "3"}})
+	if q.Encode() != "a=1&a=3&b=2" {
+		t.Errorf("Invalid query from values: %s", q.Encode())
+	}
+}
+
+func TestBuildRequestQuery(t *testing.T) {
+	t.Parallel()
+	var q Query
+	q.Add("id", "2", "1")
+	request := Request{
+		Method:      Get,
+		BaseURL:     "http://api.test.com",
+		QueryParams: map[string]string{"limit": "10"},
+		Query:       q,
+	}
+	req, err := BuildRequestObject(request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if req.URL.String() != "http://api.test.com?limit=10&id=2&id=1" {
+		t.Errorf("Invalid request URL: %s", req.URL)
+	}
+}
+
+func TestEncodeQuery(t *testing.T) {
+	t.Parallel()
+	type filter struct {
+		Status []string `url:"status"`
+		Limit  int      `url:"limit,omitempty"`
+		Offset int      `url:"offset"`
+	}
+	q, err := EncodeQuery(filter{Status: []string{"open", "closed"}, Offset: 20})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if q.Encode() != "status=open&status=closed&offset=20" {
+		t.Errorf("Invalid encoded query: %s", q.Encode())
+	}
+}
+
+func TestBuildURLMergesQuery(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		conflict QueryConflict
+		expected string
+	}{
+		{QueryReplace, "https://api.test.com/v3/search?q=x&limit=10#results"},
+		{QueryKeep, "https://api.test.com/v3/search?q=x&limit=5#results"},
+		{QueryAppend, "https://api.test.com/v3/search?q=x&limit=5&limit=10#results"},
+	}
+	for _, test := range tests {
+		request := Request{
+			Method:        Get,
+			BaseURL:       "https://api.test.com/v3/search?q=x&limit=5#results",
+			QueryParams:   map[string]string{"limit": "10"},
+			QueryConflict: test.conflict,
+		}
+		req, err := BuildRequestObject(request)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if req.URL.String() != test.expected {
+			t.Errorf("Conflict policy %d: got %s, want %s", test.conflict, req.URL, test.expected)
+		}
+	}
+}
+
+func TestAddQueryParametersExistingQuery(t *testing.T) {
+	t.Parallel()
+	got := AddQueryParameters("https://api.test.com/v3/search?q=x", map[string]string{"limit": "10"})
+	if got != "https://api.test.com/v3/search?q=x&limit=10" {
+		t.Errorf("Existing query string was not merged: %s", got)
+	}
+}
+
+func TestBuildURLKeepsRawQuery(t *testing.T) {
+	t.Parallel()
+	baseURL := "https://bucket.test.com/file?flag&q=a%20b&X-Amz-Signature=abc%2F"
+	got := AddQueryParameters(baseURL, map[string]string{"limit": "10"})
+	if got != baseURL+"&limit=10" {
+		t.Errorf("Existing query string was rewritten: %s", got)
+	}
+
+	request := Request{
+		Method:      Get,
+		BaseURL:     "https://api.test.com/v3/search?flag&q=a%20b&limit=5",
+		QueryParams: map[string]string{"limit": "10"},
+	}
+	u, err := buildURL(request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if u != "https://api.test.com/v3/search?flag&q=a%20b&limit=10" {
+		t.Errorf("Only the conflicting key should be replaced: %s", u)
+	}
+}
+
+func TestBuildRequestInvalidURL(t *testing.T) {
+	t.Parallel()
+	for _, baseURL := range []string{"/v3/api_keys", "http://[::1", "https://api.test.com?q=%zz"} {
+		request := Request{Method: Get, BaseURL: baseURL, QueryParams: map[string]string{"limit": "10"}}
+		req, err := BuildRequestObject(request)
+		if err == nil || req != nil {
+			t.Errorf("Expected an error for base URL %q", baseURL)
+		} else if !strings.Contains(err.Error(), "invalid base URL") {
+			t.Errorf("Unclear error for base URL %q: %v", baseURL, err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimit holds the rate-limit state reported by a response's headers.
+type RateLimit struct {
+	Limit      int           // X-RateLimit-Limit, -1 if absent
+	Remaining  int           // X-RateLimit-Remaining, -1 if absent
+	Reset      time.Time     // X-RateLimit-Reset, zero if absent
+	RetryAfter time.Duration // Retry-After, zero if absent
+}
+
+// ParseRateLimit reads the Retry-After and X-RateLimit-* headers.
+// It returns nil if none of them are present.
+//
+// Retry-After may be given in seconds or as an HTTP-date. X-RateLimit-Reset
+// may be a Unix timestamp or a number of seconds from now.
+func ParseRateLimit(headers map[string][]string) *RateLimit {
+	h := http.Header(headers)
+	now := time.Now()
+	rl := &RateLimit{Limit: -1, Remaining: -1}
+	found := false
+
+	if v, err := strconv.Atoi(h.Get("X-RateLimit-Limit")); err == nil {
+		rl.Limit = v
+		found = true
+	}
+	if v, err := strconv.Atoi(h.Get("X-RateLimit-Remaining")); err == nil {
+		rl.Remaining = v
+		found = true
+	}
+	if v, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		// Anything this large is an epoch timestamp rather than a delta.
+		if v >= 1e9 {
+			rl.Reset = time.Unix(v, 0)
+		} else {
+			rl.Reset = now.Add(time.Duration(v) * time.Second)
+		}
+		found = true
+	}
+	if v := h.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil {
+			rl.RetryAfter = time.Duration(secs) * time.Second
+			found = true
+		} else if t, err := http.ParseTime(v); err == nil {
+			if t.After(now) {
+				rl.RetryAfter = t.Sub(now)
+			}
+			found = true
+		}
+	}
+	if !found {
+		return nil
+	}
+	return rl
+}
+
+// RateLimitTracker pauses requests to a host once a response from that host
+// says its rate limit is exhausted. It is safe for concurrent use, so a
+// single tracker coordinates every goroutine sharing a Client.
+// The zero value is ready to use.
+type RateLimitTracker struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+// Wait blocks until requests to host are allowed or ctx is done.
+func (t *RateLimitTracker) Wait(ctx context.Context, host string) error {
+	for {
+		t.mu.Lock()
+		until := t.until[host]
+		t.mu.Unlock()
+
+		d := time.Until(until)
+		if d <= 0 {
+			return nil
+		}
+		if err := sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+// Update records the rate-limit state last reported by host.
+func (t *RateLimitTracker) Update(host string, rl *RateLimit) {
+	if rl == nil {
+		return
+	}
+	var until time.Time
+	switch {
+	case rl.RetryAfter > 0:
+		until = time.Now().Add(rl.RetryAfter)
+	case rl.Remaining == 0 && !rl.Reset.IsZero():
+		until = rl.Reset
+	default:
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.until == nil {
+		t.until = make(map[string]time.Time)
+	}
+	if until.After(t.until[host]) {
+		t.until[host] = until
+	}
+}
@@ -0,0 +1,146 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	t.Parallel()
+	reset := time.Now().Add(time.Minute).Unix()
+	headers := map[string][]string{
+		"X-Ratelimit-Limit":     {"600"},
+		"X-Ratelimit-Remaining": {"0"},
+		"X-Ratelimit-Reset":     {strconv.FormatInt(reset, 10)},
+		"Retry-After":           {"2"},
+	}
+	rl := ParseRateLimit(headers)
+	if rl == nil {
+		t.Fatal("Rate limit headers were not parsed")
+	}
+	if rl.Limit != 600 || rl.Remaining != 0 {
+		t.Errorf("Invalid limit/remaining: %d/%d", rl.Limit, rl.Remaining)
+	}
+	if rl.Reset.Unix() != reset {
+		t.Errorf("Invalid reset time: %v", rl.Reset)
+	}
+	if rl.RetryAfter != 2*time.Second {
+		t.Errorf("Invalid Retry-After: %v", rl.RetryAfter)
+	}
+
+	if ParseRateLimit(map[string][]string{"Content-Type": {"application/json"}}) != nil {
+		t.Error("Expected nil RateLimit without rate-limit headers")
+	}
+}
+
+func TestParseRateLimitHTTPDate(t *testing.T) {
+	t.Parallel()
+	date := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+	rl := ParseRateLimit(map[string][]string{"Retry-After": {date}})
+	if rl == nil || rl.RetryAfter <= 8*time.Second || rl.RetryAfter > 10*time.Second {
+		t.Errorf("Invalid Retry-After from HTTP-date: %+v", rl)
+	}
+	if rl.Limit != -1 || rl.Remaining != -1 {
+		t.Error("Absent limit headers should be reported as -1")
+	}
+}
+
+func TestParseRateLimitResetDelta(t *testing.T) {
+	t.Parallel()
+	rl := ParseRateLimit(map[string][]string{"X-Ratelimit-Reset": {"30"}})
+	if d := time.Until(rl.Reset); d <= 28*time.Second || d > 30*time.Second {
+		t.Errorf("Reset given in seconds was not relative to now: %v", rl.Reset)
+	}
+}
+
+func TestResponseRateLimit(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "600")
+		w.Header().Set("X-RateLimit-Remaining", "599")
+		fmt.Fprintln(w, "{\"message\": \"success\"}")
+	}))
+	defer fakeServer.Close()
+
+	response, err := Send(Request{Method: Get, BaseURL: fakeServer.URL})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.RateLimit == nil || response.RateLimit.Remaining != 599 {
+		t.Errorf("Rate limit state not exposed on Response: %+v", response.RateLimit)
+	}
+}
+
+func TestRateLimitTracker(t *testing.T) {
+	t.Parallel()
+	var first int64
+	var requests int32
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			atomic.StoreInt64(&first, time.Now().UnixNano())
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if time.Since(time.Unix(0, atomic.LoadInt64(&first))) < 900*time.Millisecond {
+			t.Error("Request was sent before the Retry-After window elapsed")
+		}
+	}))
+	defer fakeServer.Close()
+
+	client := &Client{HTTPClient: &http.Client{}, RateLimits: &RateLimitTracker{}}
+	request := Request{Method: Get, BaseURL: fakeServer.URL}
+	response, err := client.Send(request)
+	if err != nil || response.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Expected a 429 response, got %v, %v", response, err)
+	}
+	if _, err := client.Send(request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestRateLimitTrackerContext(t *testing.T) {
+	t.Parallel()
+	tracker := &RateLimitTracker{}
+	tracker.Update("api.test.com", &RateLimit{Remaining: 0, Reset: time.Now().Add(time.Hour)})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	if err := tracker.Wait(ctx, "api.test.com"); err != context.DeadlineExceeded {
+		t.Errorf("Expected context deadline exceeded, got %v", err)
+	}
+	if err := tracker.Wait(ctx, "other.test.com"); err != nil {
+		t.Errorf("Other hosts should not be paused, got %v", err)
+	}
+}
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	t.Parallel()
+	var requests int32
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer fakeServer.Close()
+
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	client := &Client{HTTPClient: &http.Client{}, RetryPolicy: policy}
+	start := time.Now()
+	response, err := client.Send(Request{Method: Get, BaseURL: fakeServer.URL})
+	if err != nil || response.StatusCode != 200 {
+		t.Fatalf("Expected a successful retry, got %v, %v", response, err)
+	}
+	if time.Since(start) < 900*time.Millisecond {
+		t.Error("Retry did not wait for Retry-After")
+	}
+}
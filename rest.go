@@ -4,9 +4,13 @@ package rest
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"time"
 )
 
 // Version represents the current version of the rest library
@@ -31,16 +35,52 @@ type Request struct {
 	Headers     map[string]string
 	QueryParams map[string]string
 	Body        []byte
+
+	// PathParams, when set, expands the variables of a BaseURL written as
+	// a template, e.g. https://api.sendgrid.com/v3/templates/{template_id}.
+	// See ExpandPath.
+	PathParams map[string]string
+
+	// Query is added to the URL after QueryParams, in order, and may
+	// repeat keys.
+	Query Query
+	// QueryConflict decides how QueryParams and Query merge with a query
+	// string already present in BaseURL. By default they replace it.
+	QueryConflict QueryConflict
+
+	// BodyReader, when set, is streamed as the request body instead of Body.
+	BodyReader io.Reader
+	// ContentLength is the length of the body from BodyReader or GetBody,
+	// or 0 if it is unknown.
+	ContentLength int64
+	// GetBody returns a new copy of BodyReader. When set, it supplies the
+	// body of every attempt, the first included, and BodyReader is not
+	// read, so the request can be retried, redirected and sent again.
+	GetBody func() (io.ReadCloser, error)
 }
 
 // RestError is a struct for an error handling.
 type RestError struct {
-	Response *Response
+	Response   *Response
+	StatusCode int      // e.g. 404
+	Method     Method   // e.g. GET
+	URL        string   // e.g. https://api.sendgrid.com/v3/api_keys
+	Problem    *Problem // decoded error body, nil if it could not be decoded
 }
 
 // Error is the implementation of the error interface.
 func (e *RestError) Error() string {
-	return e.Response.Body
+	// Errors built by hand from a Response only carry its body.
+	if e.StatusCode == 0 {
+		return e.Response.Body
+	}
+	msg := fmt.Sprintf("rest: %s %s: %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Problem != nil {
+		if detail := e.Problem.message(); detail != "" {
+			msg += ": " + detail
+		}
+	}
+	return msg
 }
 
 // DefaultClient is used if no custom HTTP client is defined
@@ -51,17 +91,72 @@ var DefaultClient = &Client{HTTPClient: &http.Client{}}
 // See https://golang.org/pkg/net/http
 type Client struct {
 	HTTPClient *http.Client
+
+	// RetryPolicy, when set, makes Send retry failed attempts.
+	// A nil RetryPolicy sends every request exactly once.
+	RetryPolicy *RetryPolicy
+
+	// RateLimits, when set, pauses requests to a host after it reports
+	// an exhausted rate limit via Retry-After or X-RateLimit headers.
+	RateLimits *RateLimitTracker
+
+	// ErrorOnStatus, when set, makes Send return a *RestError alongside
+	// the Response for any non-2xx status code.
+	ErrorOnStatus bool
+
+	// MaxBodySize, when positive, caps the number of bytes Send reads
+	// from a response body. Larger bodies fail with ErrBodyTooLarge.
+	MaxBodySize int64
+
+	// Middleware wraps every call to Send, in order, so the first
+	// middleware sees the request first and the response last.
+	// Do bypasses the middleware.
+	Middleware []Middleware
+
+	// Authenticator, when set, adds credentials to every attempt.
+	Authenticator Authenticator
+
+	// Signer, when set, signs every attempt after its credentials are added.
+	Signer Signer
+
+	// RateLimiter, when set, delays attempts to stay within its limits.
+	RateLimiter *RateLimiter
+
+	// Bulkhead, when set, limits the number of requests in flight to
+	// each host.
+	Bulkhead *Bulkhead
+
+	// CircuitBreaker, when set, fails attempts with ErrCircuitOpen while
+	// too many recent requests to their host have failed.
+	CircuitBreaker *CircuitBreaker
+
+	// Tracer, when set, starts a client span for every call to Send.
+	Tracer Tracer
+	// Meter, when set, records the duration and number of in-flight
+	// calls to Send.
+	Meter Meter
 }
 
+// ErrBodyTooLarge is returned when a response body exceeds Client.MaxBodySize.
+var ErrBodyTooLarge = errors.New("rest: response body too large")
+
 // Response holds the response from an API call.
 type Response struct {
 	StatusCode int                 // e.g. 200
 	Body       string              // e.g. {"result: success"}
 	Headers    map[string][]string // e.g. map[X-Ratelimit-Limit:[600]]
+	RateLimit  *RateLimit          // nil if the response has no rate-limit headers
 }
 
-// AddQueryParameters adds query parameters to the URL.
+// AddQueryParameters adds query parameters to the URL, replacing any
+// parameters of the same name already in its query string.
 func AddQueryParameters(baseURL string, queryParams map[string]string) string {
+	if u, err := url.Parse(baseURL); err == nil {
+		if query, err := mergeQuery(u.RawQuery, queryFromMap(queryParams), QueryReplace); err == nil {
+			u.RawQuery = query
+			return u.String()
+		}
+	}
 	baseURL += "?"
 	params := url.Values{}
 	for key, value := range queryParams {
@@ -70,21 +165,105 @@ func AddQueryParameters(baseURL string, queryParams map[string]string) string {
 	return baseURL + params.Encode()
 }
 
+// setHeader sets a header on a copy of the request's headers, so maps
+// shared between requests are left untouched.
+func (r *Request) setHeader(key, value string) {
+	headers := make(map[string]string, len(r.Headers)+1)
+	for k, v := range r.Headers {
+		headers[k] = v
+	}
+	headers[key] = value
+	r.Headers = headers
+}
+
+// hasHeader reports whether headers sets key, whatever the case of its key.
+func hasHeader(headers map[string]string, key string) bool {
+	key = http.CanonicalHeaderKey(key)
+	for k := range headers {
+		if http.CanonicalHeaderKey(k) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// buildURL returns the URL the request is sent to. Its query parameters
+// are merged into any query string in BaseURL and its fragment is kept.
+func buildURL(request Request) (string, error) {
+	if request.PathParams != nil {
+		expanded, err := ExpandPath(request.BaseURL, request.PathParams)
+		if err != nil {
+			return "", err
+		}
+		request.BaseURL = expanded
+	}
+	u, err := url.Parse(request.BaseURL)
+	if err != nil {
+		return "", fmt.Errorf("rest: invalid base URL: %v", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("rest: invalid base URL %q: scheme and host are required", request.BaseURL)
+	}
+	if len(request.QueryParams) == 0 && len(request.Query) == 0 {
+		return request.BaseURL, nil
+	}
+	params := append(queryFromMap(request.QueryParams), request.Query...)
+	query, err := mergeQuery(u.RawQuery, params, request.QueryConflict)
+	if err != nil {
+		return "", fmt.Errorf("rest: invalid base URL %q: %v", request.BaseURL, err)
+	}
+	u.RawQuery = query
+	return u.String(), nil
+}
+
+// requestURL returns the URL the request is sent to, falling back to
+// BaseURL if it cannot be built.
+func requestURL(request Request) string {
+	if u, err := buildURL(request); err == nil {
+		return u
+	}
+	return request.BaseURL
+}
+
 // BuildRequestObject creates the HTTP request object.
 func BuildRequestObject(request Request) (*http.Request, error) {
 	// Add any query parameters to the URL.
-	if len(request.QueryParams) != 0 {
-		request.BaseURL = AddQueryParameters(request.BaseURL, request.QueryParams)
+	u, err := buildURL(request)
+	if err != nil {
+		return nil, err
 	}
-	req, err := http.NewRequest(string(request.Method), request.BaseURL, bytes.NewBuffer(request.Body))
+	streamed := request.BodyReader != nil || request.GetBody != nil
+	var body io.Reader = bytes.NewBuffer(request.Body)
+	switch {
+	case request.GetBody != nil:
+		rc, err := request.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		body = rc
+	case request.BodyReader != nil:
+		body = request.BodyReader
+	}
+	req, err := http.NewRequest(string(request.Method), u, body)
 	if err != nil {
+		if rc, ok := body.(io.Closer); ok && request.GetBody != nil {
+			rc.Close() // nolint
+		}
 		return req, err
 	}
+	if streamed {
+		if request.ContentLength > 0 {
+			req.ContentLength = request.ContentLength
+		}
+		if request.GetBody != nil {
+			req.GetBody = request.GetBody
+		}
+	}
 	for key, value := range request.Headers {
 		req.Header.Set(key, value)
 	}
 	_, exists := req.Header["Content-Type"]
-	if len(request.Body) > 0 && !exists {
+	if (len(request.Body) > 0 || streamed) && !exists {
 		req.Header.Set("Content-Type", "application/json")
 	}
 	return req, err
@@ -97,11 +276,26 @@ func MakeRequest(req *http.Request) (*http.Response, error) {
 
 // BuildResponse builds the response struct.
 func BuildResponse(res *http.Response) (*Response, error) {
-	body, err := ioutil.ReadAll(res.Body)
+	return buildResponse(res, 0)
+}
+
+// buildResponse builds the response struct, reading at most maxBodySize
+// bytes of the body if maxBodySize is positive.
+func buildResponse(res *http.Response, maxBodySize int64) (*Response, error) {
+	var reader io.Reader = res.Body
+	if maxBodySize > 0 {
+		reader = io.LimitReader(res.Body, maxBodySize+1)
+	}
+	body, err := ioutil.ReadAll(reader)
+	if maxBodySize > 0 && int64(len(body)) > maxBodySize {
+		res.Body.Close() // nolint
+		return nil, ErrBodyTooLarge
+	}
 	response := Response{
 		StatusCode: res.StatusCode,
 		Body:       string(body),
 		Headers:    res.Header,
+		RateLimit:  ParseRateLimit(res.Header),
 	}
 	res.Body.Close() // nolint
 	return &response, err
@@ -122,6 +316,12 @@ func SendWithContext(ctx context.Context, request Request) (*Response, error) {
 	return DefaultClient.SendWithContext(ctx, request)
 }
 
+// Do uses the DefaultClient to send your request and returns the
+// unbuffered HTTP response.
+func Do(ctx context.Context, request Request) (*http.Response, error) {
+	return DefaultClient.Do(ctx, request)
+}
+
 // The following functions enable the ability to define a
 // custom HTTP Client
 
@@ -142,20 +342,203 @@ func (c *Client) Send(request Request) (*Response, error) {
 
 // SendWithContext will build your request passing in the provided context, make the request, and build your response.
 func (c *Client) SendWithContext(ctx context.Context, request Request) (*Response, error) {
-	// Build the HTTP request object.
+	handler := Handler(c.send)
+	for i := len(c.Middleware) - 1; i >= 0; i-- {
+		handler = c.Middleware[i](handler)
+	}
+	if c.Tracer != nil || c.Meter != nil {
+		return c.instrument(ctx, request, handler)
+	}
+	return handler(ctx, request)
+}
+
+// send is the Handler at the end of the middleware chain.
+func (c *Client) send(ctx context.Context, request Request) (*Response, error) {
+	// Make the request, retrying if the client has a retry policy.
+	res, err := c.Do(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build Response object.
+	response, err := buildResponse(res, c.MaxBodySize)
+	if err != nil || !c.ErrorOnStatus {
+		return response, err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response, newRestError(request, response)
+	}
+	return response, nil
+}
+
+// Do sends the request, retrying according to the client's RetryPolicy, and
+// returns the HTTP response without reading its body. Use it to stream large
+// responses; the caller must close the response body.
+func (c *Client) Do(ctx context.Context, request Request) (*http.Response, error) {
+	reauthenticated := false
+	resends := 0
+	for attempt := 1; ; attempt++ {
+		res, err := c.attempt(ctx, request)
+
+		var delay time.Duration
+		switch {
+		case err == nil && !reauthenticated && c.invalidateCredentials(res):
+			// Resend once with renewed credentials. This does not count
+			// as an attempt of the retry policy.
+			reauthenticated = true
+			attempt--
+		case c.RetryPolicy != nil && c.RetryPolicy.shouldRetry(ctx, attempt, request.Method, res, err):
+			delay = c.RetryPolicy.Backoff(attempt)
+			// Never retry sooner than the server asked us to.
+			if res != nil {
+				if rl := ParseRateLimit(res.Header); rl != nil && rl.RetryAfter > delay {
+					delay = rl.RetryAfter
+				}
+			}
+		default:
+			return res, err
+		}
+
+		// A streamed body can only be resent if it can be recreated.
+		if request.BodyReader != nil && request.GetBody == nil {
+			return res, err
+		}
+		resends++
+		recordResend(ctx, resends, res, err)
+		if res != nil {
+			discardBody(res)
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// invalidateCredentials reports whether res was rejected with a 401 by a
+// server the client's Authenticator can renew its credentials for.
+func (c *Client) invalidateCredentials(res *http.Response) bool {
+	if res.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+	invalidator, ok := c.Authenticator.(Invalidator)
+	if !ok {
+		return false
+	}
+	invalidator.Invalidate(res.Request)
+	return true
+}
+
+// attempt makes a single round trip for the request.
+func (c *Client) attempt(ctx context.Context, request Request) (*http.Response, error) {
+	// Build the HTTP request object. The body is rebuilt from
+	// request.Body or request.GetBody on every attempt, so retries
+	// resend it in full.
 	req, err := BuildRequestObject(request)
 	if err != nil {
 		return nil, err
 	}
 	// Pass in the user provided context
 	req = req.WithContext(ctx)
+	host := req.URL.Host
+
+	var circuitKey string
+	var generation uint64
+	if c.CircuitBreaker != nil {
+		circuitKey = c.CircuitBreaker.key(host, request)
+		if generation, err = c.CircuitBreaker.allow(circuitKey); err != nil {
+			closeBody(req)
+			return nil, err
+		}
+	}
+	if err := c.wait(ctx, host, request); err != nil {
+		if c.CircuitBreaker != nil {
+			c.CircuitBreaker.release(circuitKey, generation)
+		}
+		closeBody(req)
+		return nil, err
+	}
+
+	// Credentials and signatures are added last, after any wait, so
+	// tokens and signed timestamps are fresh when the request is sent.
+	if err := c.prepare(ctx, req); err != nil {
+		if c.Bulkhead != nil {
+			c.Bulkhead.release(host)
+		}
+		if c.CircuitBreaker != nil {
+			c.CircuitBreaker.release(circuitKey, generation)
+		}
+		closeBody(req)
+		return nil, err
+	}
 
 	// Build the HTTP client and make the request.
 	res, err := c.MakeRequest(req)
-	if err != nil {
-		return nil, err
+	if c.Bulkhead != nil {
+		release := func() { c.Bulkhead.release(host) }
+		if err != nil {
+			release()
+		} else {
+			res.Body = &releaseBody{ReadCloser: res.Body, release: release}
+		}
+	}
+	if err == nil && c.RateLimits != nil {
+		c.RateLimits.Update(host, ParseRateLimit(res.Header))
 	}
+	if c.CircuitBreaker != nil {
+		c.CircuitBreaker.done(circuitKey, generation, res, err)
+	}
+	return res, err
+}
 
-	// Build Response object.
-	return BuildResponse(res)
+// prepare adds the trace context, credentials and signature to req.
+// Credentials are added to the HTTP request only, keeping them out of
+// the Request seen by middleware.
+func (c *Client) prepare(ctx context.Context, req *http.Request) error {
+	if span := spanFromContext(ctx); span != nil {
+		if traceParent := span.TraceParent(); traceParent != "" {
+			req.Header.Set("Traceparent", traceParent)
+		}
+	}
+	if c.Authenticator != nil {
+		if err := c.Authenticator.Authenticate(ctx, req); err != nil {
+			return err
+		}
+	}
+	if c.Signer != nil {
+		return c.Signer.Sign(ctx, req)
+	}
+	return nil
+}
+
+// closeBody closes the body of a request that will not be sent.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close() // nolint
+	}
+}
+
+// wait takes a Bulkhead slot for a request to host and blocks until the
+// client's rate limits allow it.
+func (c *Client) wait(ctx context.Context, host string, request Request) error {
+	if c.RateLimits != nil {
+		if err := c.RateLimits.Wait(ctx, host); err != nil {
+			return err
+		}
+	}
+	// The bulkhead slot is taken first, so requests it rejects do not use
+	// up rate limiter tokens.
+	if c.Bulkhead != nil {
+		if err := c.Bulkhead.acquire(ctx, host); err != nil {
+			return err
+		}
+	}
+	if c.RateLimiter != nil {
+		if err := c.RateLimiter.waitRequest(ctx, host, request); err != nil {
+			if c.Bulkhead != nil {
+				c.Bulkhead.release(host)
+			}
+			return err
+		}
+	}
+	return nil
 }
@@ -281,7 +281,7 @@ func TestCustomHTTPClient(t *testing.T) {
 		BaseURL: baseURL,
 	}
 
-	customClient := &Client{&http.Client{Timeout: time.Millisecond * 10}}
+	customClient := &Client{HTTPClient: &http.Client{Timeout: time.Millisecond * 10}}
 	_, err := customClient.Send(request)
 	if err == nil {
 		t.Error("A timeout did not trigger as expected")
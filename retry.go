@@ -0,0 +1,128 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy describes how a Client retries a request that failed with a
+// retryable status code or network error.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts.
+	MaxBackoff time.Duration
+	// Multiplier grows the delay after every attempt, e.g. 2.
+	Multiplier float64
+	// Jitter is the fraction of each delay, between 0 and 1, that is
+	// randomized to spread out retries from concurrent callers.
+	Jitter float64
+	// RetryableStatusCodes lists the response codes that trigger a retry.
+	RetryableStatusCodes []int
+	// RetryNonIdempotent also retries POST and PATCH requests that failed
+	// with a network error. Such a request may already have been
+	// processed by the server, so it is sent again only if this is set.
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy returns a RetryPolicy that makes up to three attempts,
+// backing off exponentially from 100ms, and retries 429, 502, 503 and 504
+// responses as well as transient network errors of idempotent requests.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.5,
+		RetryableStatusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// Backoff returns the delay to wait after the given attempt, counting from 1.
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	delay := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		delay -= delay * jitter * rand.Float64()
+	}
+	return time.Duration(delay)
+}
+
+// shouldRetry reports whether another attempt should follow the given one.
+func (p *RetryPolicy) shouldRetry(ctx context.Context, attempt int, method Method, res *http.Response, err error) bool {
+	if attempt >= p.MaxAttempts || ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return (p.RetryNonIdempotent || isIdempotent(method)) && isRetryableError(err)
+	}
+	for _, code := range p.RetryableStatusCodes {
+		if res.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// isIdempotent reports whether sending a request with method twice has the
+// same effect as sending it once.
+func isIdempotent(method Method) bool {
+	switch method {
+	case Post, Patch:
+		return false
+	}
+	return true
+}
+
+// isRetryableError reports whether err is a transient network error,
+// including a per-attempt http.Client.Timeout. The caller's own
+// cancellation is checked on ctx by shouldRetry.
+func isRetryableError(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// discardBody drains and closes a response body so the connection can be reused.
+func discardBody(res *http.Response) {
+	io.Copy(ioutil.Discard, res.Body) // nolint
+	res.Body.Close()                  // nolint
+}
+
+// sleep waits for d or until ctx is done, whichever comes first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
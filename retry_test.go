@@ -0,0 +1,204 @@
+package rest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	t.Parallel()
+	var attempts int32
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != "Hello World" {
+			t.Errorf("Request body was not resent, got %q", body)
+		}
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "{\"message\": \"success\"}")
+	}))
+	defer fakeServer.Close()
+
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	client := &Client{HTTPClient: &http.Client{}, RetryPolicy: policy}
+	response, err := client.Send(Request{
+		Method:  Post,
+		BaseURL: fakeServer.URL,
+		Body:    []byte("Hello World"),
+	})
+	if err != nil {
+		t.Fatalf("Rest failed to make a valid API request. Returned error: %v", err)
+	}
+	if response.StatusCode != 200 {
+		t.Errorf("Expected status 200 after retries, got %d", response.StatusCode)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestRetryPolicyMaxAttempts(t *testing.T) {
+	t.Parallel()
+	var attempts int32
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer fakeServer.Close()
+
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	client := &Client{HTTPClient: &http.Client{}, RetryPolicy: policy}
+	response, err := client.Send(Request{Method: Get, BaseURL: fakeServer.URL})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected the last response to be returned, got %d", response.StatusCode)
+	}
+	if attempts != int32(policy.MaxAttempts) {
+		t.Errorf("Expected %d attempts, got %d", policy.MaxAttempts, attempts)
+	}
+}
+
+func TestRetryPolicyNotRetryable(t *testing.T) {
+	t.Parallel()
+	var attempts int32
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer fakeServer.Close()
+
+	client := &Client{HTTPClient: &http.Client{}, RetryPolicy: DefaultRetryPolicy()}
+	if _, err := client.Send(Request{Method: Get, BaseURL: fakeServer.URL}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("A 400 response should not be retried, got %d attempts", attempts)
+	}
+}
+
+func TestRetryPolicyContextCancel(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer fakeServer.Close()
+
+	policy := DefaultRetryPolicy()
+	policy.MaxAttempts = 10
+	policy.InitialBackoff = time.Second
+	client := &Client{HTTPClient: &http.Client{}, RetryPolicy: policy}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	start := time.Now()
+	_, err := client.SendWithContext(ctx, Request{Method: Get, BaseURL: fakeServer.URL})
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected context deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Retry backoff did not respect context cancellation")
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	t.Parallel()
+	policy := &RetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+	}
+	expected := []time.Duration{100, 200, 400, 800, 1000}
+	for i, want := range expected {
+		if got := policy.Backoff(i + 1); got != want*time.Millisecond {
+			t.Errorf("Backoff(%d) = %v, want %v", i+1, got, want*time.Millisecond)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := policy.Backoff(2); got < 100*time.Millisecond || got > 200*time.Millisecond {
+			t.Fatalf("Backoff with jitter out of range: %v", got)
+		}
+	}
+}
+
+func TestRetryPolicyClientTimeout(t *testing.T) {
+	t.Parallel()
+	var attempts int32
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			time.Sleep(200 * time.Millisecond)
+		}
+	}))
+	defer fakeServer.Close()
+
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	client := &Client{HTTPClient: &http.Client{Timeout: 50 * time.Millisecond}, RetryPolicy: policy}
+	response, err := client.Send(Request{Method: Get, BaseURL: fakeServer.URL})
+	if err != nil {
+		t.Fatalf("Expected a timed out attempt to be retried, got %v", err)
+	}
+	if response.StatusCode != 200 {
+		t.Errorf("Expected status 200 after a retry, got %d", response.StatusCode)
+	}
+	if n := atomic.LoadInt32(&attempts); n != 2 {
+		t.Errorf("Expected 2 attempts, got %d", n)
+	}
+}
+
+func TestRetryPolicyNonIdempotent(t *testing.T) {
+	t.Parallel()
+	var attempts int32
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		// Drop the connection after the request has been received.
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Hijack failed: %v", err)
+			return
+		}
+		conn.Close() // nolint
+	}))
+	defer fakeServer.Close()
+
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	client := &Client{HTTPClient: &http.Client{}, RetryPolicy: policy}
+
+	tests := []struct {
+		method             Method
+		retryNonIdempotent bool
+		attempts           int32
+	}{
+		{Post, false, 1},
+		{Patch, false, 1},
+		{Put, false, 3},
+		{Get, false, 3},
+		{Post, true, 3},
+	}
+	for _, test := range tests {
+		atomic.StoreInt32(&attempts, 0)
+		policy.RetryNonIdempotent = test.retryNonIdempotent
+		if _, err := client.Send(Request{Method: test.method, BaseURL: fakeServer.URL}); err == nil {
+			t.Errorf("%s: expected a network error", test.method)
+		}
+		if n := atomic.LoadInt32(&attempts); n != test.attempts {
+			t.Errorf("%s (RetryNonIdempotent=%v): expected %d attempts, got %d",
+				test.method, test.retryNonIdempotent, test.attempts, n)
+		}
+	}
+}
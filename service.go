@@ -0,0 +1,151 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Service sends requests to a single API. It holds the base URL, and the
+// headers and query parameters shared by every request, so calls only need
+// a path:
+//
+//	svc := &rest.Service{
+//		BaseURL: "https://api.sendgrid.com",
+//		Headers: map[string]string{"Authorization": "Bearer " + key},
+//	}
+//	response, err := svc.Get(ctx, "/v3/api_keys", rest.WithQueryParam("limit", "100"))
+type Service struct {
+	Client      *Client           // uses DefaultClient if nil
+	BaseURL     string            // e.g. https://api.sendgrid.com
+	Headers     map[string]string // sent with every request
+	QueryParams map[string]string // sent with every request
+	UserAgent   string            // sets the User-Agent header if not empty
+}
+
+// RequestOption customizes a single request made through a Service.
+type RequestOption func(*Request)
+
+// WithHeader sets a header, overriding the service's default whatever the
+// case of its key.
+func WithHeader(key, value string) RequestOption {
+	return func(r *Request) {
+		r.Headers[http.CanonicalHeaderKey(key)] = value
+	}
+}
+
+// WithQueryParam sets a query parameter, overriding the service's default.
+func WithQueryParam(key, value string) RequestOption {
+	return func(r *Request) {
+		r.QueryParams[key] = value
+	}
+}
+
+// WithQuery appends repeated or ordered query parameters.
+func WithQuery(query Query) RequestOption {
+	return func(r *Request) {
+		r.Query = append(r.Query, query...)
+	}
+}
+
+// WithPathParams expands the variables of a templated path. See ExpandPath.
+func WithPathParams(params map[string]string) RequestOption {
+	return func(r *Request) {
+		r.PathParams = params
+	}
+}
+
+// WithBody sets the request body.
+func WithBody(body []byte) RequestOption {
+	return func(r *Request) {
+		r.Body = body
+	}
+}
+
+// NewRequest builds the Request for path, which is appended to the
+// service's BaseURL unless it is already an absolute URL.
+func (s *Service) NewRequest(method Method, path string, opts ...RequestOption) Request {
+	request := Request{
+		Method:      method,
+		BaseURL:     s.resolve(path),
+		Headers:     make(map[string]string, len(s.Headers)+1),
+		QueryParams: make(map[string]string, len(s.QueryParams)),
+	}
+	if s.UserAgent != "" {
+		request.Headers["User-Agent"] = s.UserAgent
+	}
+	for key, value := range s.Headers {
+		request.Headers[http.CanonicalHeaderKey(key)] = value
+	}
+	for key, value := range s.QueryParams {
+		request.QueryParams[key] = value
+	}
+	for _, opt := range opts {
+		opt(&request)
+	}
+	return request
+}
+
+// Send builds the request for path and sends it with the service's client.
+func (s *Service) Send(ctx context.Context, method Method, path string, opts ...RequestOption) (*Response, error) {
+	client := s.Client
+	if client == nil {
+		client = DefaultClient
+	}
+	return client.SendWithContext(ctx, s.NewRequest(method, path, opts...))
+}
+
+// Get sends a GET request for path.
+func (s *Service) Get(ctx context.Context, path string, opts ...RequestOption) (*Response, error) {
+	return s.Send(ctx, Get, path, opts...)
+}
+
+// Post sends a POST request for path.
+func (s *Service) Post(ctx context.Context, path string, opts ...RequestOption) (*Response, error) {
+	return s.Send(ctx, Post, path, opts...)
+}
+
+// Put sends a PUT request for path.
+func (s *Service) Put(ctx context.Context, path string, opts ...RequestOption) (*Response, error) {
+	return s.Send(ctx, Put, path, opts...)
+}
+
+// Patch sends a PATCH request for path.
+func (s *Service) Patch(ctx context.Context, path string, opts ...RequestOption) (*Response, error) {
+	return s.Send(ctx, Patch, path, opts...)
+}
+
+// Delete sends a DELETE request for path.
+func (s *Service) Delete(ctx context.Context, path string, opts ...RequestOption) (*Response, error) {
+	return s.Send(ctx, Delete, path, opts...)
+}
+
+// resolve joins path to the service's BaseURL, keeping any query string
+// of BaseURL after the joined path.
+func (s *Service) resolve(path string) string {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	if path == "" {
+		return s.BaseURL
+	}
+	u, err := url.Parse(s.BaseURL)
+	if err != nil {
+		return strings.TrimRight(s.BaseURL, "/") + "/" + strings.TrimLeft(path, "/")
+	}
+	query, fragment := u.RawQuery, u.Fragment
+	u.RawQuery, u.Fragment = "", ""
+	resolved := strings.TrimRight(u.String(), "/") + "/" + strings.TrimLeft(path, "/")
+	if query != "" {
+		if strings.Contains(path, "?") {
+			resolved += "&" + query
+		} else {
+			resolved += "?" + query
+		}
+	}
+	if fragment != "" {
+		resolved += "#" + url.PathEscape(fragment)
+	}
+	return resolved
+}
@@ -0,0 +1,74 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestService(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v3/templates/d-1/versions" {
+			t.Errorf("Invalid request: %s %s", r.Method, r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer API_KEY" || r.Header.Get("X-Test") != "override" {
+			t.Errorf("Invalid headers: %v", r.Header)
+		}
+		if r.Header.Get("User-Agent") != "rest-test/1.0" {
+			t.Errorf("Invalid User-Agent: %s", r.Header.Get("User-Agent"))
+		}
+		if r.URL.Query().Get("on-behalf-of") != "sub" || r.URL.Query().Get("limit") != "10" {
+			t.Errorf("Invalid query: %s", r.URL.RawQuery)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer fakeServer.Close()
+
+	svc := &Service{
+		BaseURL:     fakeServer.URL + "/",
+		Headers:     map[string]string{"Authorization": "Bearer API_KEY", "X-Test": "default"},
+		QueryParams: map[string]string{"on-behalf-of": "sub"},
+		UserAgent:   "rest-test/1.0",
+	}
+	response, err := svc.Post(context.Background(), "/v3/templates/{template_id}/versions",
+		WithPathParams(map[string]string{"template_id": "d-1"}),
+		WithHeader("x-test", "override"),
+		WithQueryParam("limit", "10"),
+		WithBody([]byte(`{"name": "v1"}`)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.StatusCode != http.StatusCreated {
+		t.Errorf("Invalid status code: %d", response.StatusCode)
+	}
+	if svc.Headers["X-Test"] != "default" {
+		t.Error("Per-call options should not modify the service defaults")
+	}
+	request := svc.NewRequest(Get, "/", WithHeader("x-test", "override"))
+	if len(request.Headers) != 3 || request.Headers["X-Test"] != "override" {
+		t.Errorf("Header override was not merged case-insensitively: %v", request.Headers)
+	}
+}
+
+func TestServiceResolve(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		baseURL, path, expected string
+	}{
+		{"https://api.test.com/v3", "/api_keys", "https://api.test.com/v3/api_keys"},
+		{"https://api.test.com/v3", "api_keys", "https://api.test.com/v3/api_keys"},
+		{"https://api.test.com/v3", "", "https://api.test.com/v3"},
+		{"https://api.test.com/v3", "https://other.test.com/x?y", "https://other.test.com/x?y"},
+		{"https://api.test.com/v3/?on-behalf-of=sub", "/api_keys", "https://api.test.com/v3/api_keys?on-behalf-of=sub"},
+		{"https://api.test.com/v3?on-behalf-of=sub", "/api_keys?limit=1", "https://api.test.com/v3/api_keys?limit=1&on-behalf-of=sub"},
+	}
+	for _, test := range tests {
+		svc := &Service{BaseURL: test.baseURL}
+		if got := svc.NewRequest(Get, test.path).BaseURL; got != test.expected {
+			t.Errorf("resolve(%q, %q) = %q, want %q", test.baseURL, test.path, got, test.expected)
+		}
+	}
+}
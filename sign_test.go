@@ -0,0 +1,182 @@
+package rest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Credentials and date of the AWS Signature Version 4 test suite.
+var sigV4TestSigner = &AWSSigV4Signer{
+	AccessKeyID:     "AKIDEXAMPLE",
+	SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	Region:          "us-east-1",
+	Service:         "service",
+	Now: func() time.Time {
+		return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	},
+}
+
+func TestAWSSigV4Signer(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name, method, url, signature string
+	}{
+		{"get-vanilla", "GET", "https://example.amazonaws.com/",
+			"5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"post-vanilla", "POST", "https://example.amazonaws.com/",
+			"5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b"},
+		{"get-vanilla-query-order-key-case", "GET", "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			"b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+		{"get-query-prefix-key", "GET", "https://example.amazonaws.com/?Param1=value1&Param=value2",
+			"5030aa4e7c1b2cb7a8fcac141108a3a8a308b84e4b38268282fbbedb81b80675"},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest(test.method, test.url, nil)
+		if err := sigV4TestSigner.Sign(context.Background(), req); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+			"SignedHeaders=host;x-amz-date, Signature=" + test.signature
+		if got := req.Header.Get("Authorization"); got != expected {
+			t.Errorf("%s: Authorization = %s, want %s", test.name, got, expected)
+		}
+		if req.Header.Get("X-Amz-Date") != "20150830T123600Z" {
+			t.Errorf("%s: invalid X-Amz-Date: %s", test.name, req.Header.Get("X-Amz-Date"))
+		}
+	}
+}
+
+func TestHMACSigner(t *testing.T) {
+	t.Parallel()
+	// RFC 4231 test case 2.
+	signer := &HMACSigner{
+		Secret: []byte("Jefe"),
+		Canonicalize: func(req *http.Request, bodyHash, timestamp string) string {
+			return "what do ya want for nothing?"
+		},
+	}
+	req, _ := http.NewRequest("GET", "https://api.test.com/", nil)
+	if err := signer.Sign(context.Background(), req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+	if got := req.Header.Get("X-Signature"); got != expected {
+		t.Errorf("X-Signature = %s, want %s", got, expected)
+	}
+}
+
+func TestHMACSignerCanonicalString(t *testing.T) {
+	t.Parallel()
+	req, _ := http.NewRequest("POST", "https://api.test.com/v3/mail%20send?b=2&a=1&a=0", strings.NewReader("Hello World"))
+	bodyHash, err := payloadHash(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if bodyHash != "a591a6d40bf420404a011733cfb7b190d62c65bf0bcda32b57b277d9ad9f146e" {
+		t.Errorf("Invalid body hash: %s", bodyHash)
+	}
+	expected := "POST\n/v3/mail%20send\na=0&a=1&b=2\n" + bodyHash + "\n1440938160"
+	if got := CanonicalString(req, bodyHash, "1440938160"); got != expected {
+		t.Errorf("CanonicalString = %q, want %q", got, expected)
+	}
+
+	// Keys are sorted before values, even when one key prefixes another.
+	req, _ = http.NewRequest("GET", "https://api.test.com/?id2=2&id=1", nil)
+	expected = "GET\n/\nid=1&id2=2\n" + bodyHash + "\n1440938160"
+	if got := CanonicalString(req, bodyHash, "1440938160"); got != expected {
+		t.Errorf("CanonicalString = %q, want %q", got, expected)
+	}
+}
+
+func TestClientSigner(t *testing.T) {
+	t.Parallel()
+	secret := []byte("secret")
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != `{"name": "test"}` {
+			t.Errorf("Signing consumed the request body: %q", body)
+		}
+		sum := sha256.Sum256(body)
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(CanonicalString(r, hex.EncodeToString(sum[:]), r.Header.Get("X-Timestamp"))))
+		if !hmac.Equal([]byte(r.Header.Get("X-Signature")), []byte(hex.EncodeToString(mac.Sum(nil)))) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer fakeServer.Close()
+
+	client := &Client{HTTPClient: &http.Client{}, Signer: &HMACSigner{Secret: secret}}
+	for _, request := range []Request{
+		{Method: Post, BaseURL: fakeServer.URL + "/v3/templates", QueryParams: map[string]string{"b": "1", "a": "2"},
+			Body: []byte(`{"name": "test"}`)},
+		{Method: Post, BaseURL: fakeServer.URL + "/v3/templates", GetBody: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader(`{"name": "test"}`)), nil
+		}},
+	} {
+		response, err := client.Send(request)
+		if err != nil || response.StatusCode != 200 {
+			t.Errorf("Signature was not accepted: %v, %v", response, err)
+		}
+	}
+
+	// A body that can only be read once cannot be hashed without
+	// buffering it.
+	_, err := client.Send(Request{Method: Post, BaseURL: fakeServer.URL, BodyReader: io.MultiReader(strings.NewReader("data"))})
+	if err != ErrUnsignableBody {
+		t.Errorf("Expected ErrUnsignableBody, got %v", err)
+	}
+}
+
+func TestAWSSigV4SignerUnsignedPayload(t *testing.T) {
+	t.Parallel()
+	signer := *sigV4TestSigner
+	signer.UnsignedPayload = true
+	req, _ := http.NewRequest("PUT", "https://example.amazonaws.com/object", ioutil.NopCloser(strings.NewReader("streamed")))
+	if err := signer.Sign(context.Background(), req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if req.Header.Get("X-Amz-Content-Sha256") != "UNSIGNED-PAYLOAD" {
+		t.Errorf("Invalid X-Amz-Content-Sha256: %q", req.Header.Get("X-Amz-Content-Sha256"))
+	}
+	if body, _ := ioutil.ReadAll(req.Body); string(body) != "streamed" {
+		t.Errorf("Signing read the body: %q", body)
+	}
+}
+
+func TestClientSignsAfterWaiting(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer fakeServer.Close()
+
+	var signedAt time.Time
+	client := &Client{
+		HTTPClient:  &http.Client{},
+		RateLimiter: &RateLimiter{Default: Limit{Rate: 10, Burst: 1}},
+		Signer: &HMACSigner{Secret: []byte("secret"), Now: func() time.Time {
+			signedAt = time.Now()
+			return signedAt
+		}},
+	}
+	request := Request{Method: Get, BaseURL: fakeServer.URL}
+	if _, err := client.Send(request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	start := time.Now()
+	if _, err := client.Send(request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// The second request waits about 100ms for a token.
+	if signedAt.Sub(start) < 80*time.Millisecond {
+		t.Errorf("Request was signed before waiting for the rate limiter: %v", signedAt.Sub(start))
+	}
+}
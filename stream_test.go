@@ -0,0 +1,128 @@
+package rest
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestDo(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("a,b,c\n", 1000))) // nolint
+	}))
+	defer fakeServer.Close()
+
+	res, err := Do(context.Background(), Request{Method: Get, BaseURL: fakeServer.URL})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Errorf("Invalid status code: %d", res.StatusCode)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil || len(body) != 6000 {
+		t.Errorf("Invalid streamed body: %d bytes, %v", len(body), err)
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("a", 100))) // nolint
+	}))
+	defer fakeServer.Close()
+	request := Request{Method: Get, BaseURL: fakeServer.URL}
+
+	client := &Client{HTTPClient: &http.Client{}, MaxBodySize: 99}
+	if _, err := client.Send(request); err != ErrBodyTooLarge {
+		t.Errorf("Expected ErrBodyTooLarge, got %v", err)
+	}
+
+	client.MaxBodySize = 100
+	response, err := client.Send(request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(response.Body) != 100 {
+		t.Errorf("Invalid body length: %d", len(response.Body))
+	}
+}
+
+func TestBodyReader(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength != 11 {
+			t.Errorf("Invalid content length: %d", r.ContentLength)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != "Hello World" {
+			t.Errorf("Invalid streamed request body: %q", body)
+		}
+	}))
+	defer fakeServer.Close()
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write([]byte("Hello ")) // nolint
+		pw.Write([]byte("World"))  // nolint
+		pw.Close()                 // nolint
+	}()
+	_, err := Send(Request{
+		Method:        Post,
+		BaseURL:       fakeServer.URL,
+		Headers:       map[string]string{"Content-Type": "text/plain"},
+		BodyReader:    pr,
+		ContentLength: 11,
+	})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestBodyReaderRetry(t *testing.T) {
+	t.Parallel()
+	var attempts int32
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != "Hello World" {
+			t.Errorf("Request body was not recreated, got %q", body)
+		}
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer fakeServer.Close()
+
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	client := &Client{HTTPClient: &http.Client{}, RetryPolicy: policy}
+	getBody := func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader("Hello World")), nil
+	}
+	body, _ := getBody()
+	response, err := client.Send(Request{Method: Put, BaseURL: fakeServer.URL, BodyReader: body, GetBody: getBody})
+	if err != nil || response.StatusCode != 200 {
+		t.Fatalf("Expected a successful retry, got %v, %v", response, err)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts)
+	}
+
+	atomic.StoreInt32(&attempts, 0)
+	response, err = client.Send(Request{Method: Put, BaseURL: fakeServer.URL, BodyReader: strings.NewReader("Hello World")})
+	if err != nil || response.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Expected the first response, got %v, %v", response, err)
+	}
+	if attempts != 1 {
+		t.Errorf("A body without GetBody should not be retried, got %d attempts", attempts)
+	}
+}
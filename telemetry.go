@@ -0,0 +1,184 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tracer starts client spans. It follows the shape of the OpenTelemetry
+// tracing API, so an adapter for an OpenTelemetry tracer is a few lines.
+type Tracer interface {
+	// Start starts a span as a child of any span in ctx, returning a
+	// context that carries the new span.
+	Start(ctx context.Context, name string, attrs map[string]interface{}) (context.Context, Span)
+}
+
+// Span is a single traced operation.
+type Span interface {
+	SetAttributes(attrs map[string]interface{})
+	AddEvent(name string, attrs map[string]interface{})
+	RecordError(err error)
+	End()
+	// TraceParent returns the W3C traceparent header value that
+	// identifies the span to the server, or "" to send none.
+	TraceParent() string
+}
+
+// Meter records client metrics.
+type Meter interface {
+	// RecordDuration records how long a call to Send took.
+	RecordDuration(ctx context.Context, d time.Duration, attrs map[string]interface{})
+	// AddInFlight adds delta to the number of calls in progress.
+	AddInFlight(ctx context.Context, delta int64, attrs map[string]interface{})
+}
+
+// Attribute keys from the OpenTelemetry HTTP semantic conventions.
+const (
+	AttrHTTPMethod      = "http.request.method"
+	AttrHTTPStatusCode  = "http.response.status_code"
+	AttrHTTPResendCount = "http.request.resend_count"
+	AttrHTTPRoute       = "http.route"
+	AttrURLFull         = "url.full"
+	AttrServerAddress   = "server.address"
+	AttrServerPort      = "server.port"
+	AttrErrorType       = "error.type"
+)
+
+type spanKey struct{}
+
+// spanFromContext returns the client span started by instrument, if any.
+func spanFromContext(ctx context.Context) Span {
+	span, _ := ctx.Value(spanKey{}).(Span)
+	return span
+}
+
+// instrument wraps a call to Send in a client span and records its metrics.
+func (c *Client) instrument(ctx context.Context, request Request, next Handler) (*Response, error) {
+	metricAttrs := map[string]interface{}{AttrHTTPMethod: string(request.Method)}
+	spanAttrs := map[string]interface{}{AttrHTTPMethod: string(request.Method)}
+	name := string(request.Method)
+	if u, err := url.Parse(requestURL(request)); err == nil {
+		metricAttrs[AttrServerAddress] = u.Hostname()
+		spanAttrs[AttrServerAddress] = u.Hostname()
+		if port := u.Port(); port != "" {
+			spanAttrs[AttrServerPort] = port
+		}
+		spanAttrs[AttrURLFull] = redactUserinfo(u)
+	}
+	if route := routeOf(request); route != "" {
+		metricAttrs[AttrHTTPRoute] = route
+		spanAttrs[AttrHTTPRoute] = route
+		name += " " + route
+	}
+
+	var span Span
+	if c.Tracer != nil {
+		ctx, span = c.Tracer.Start(ctx, name, spanAttrs)
+		ctx = context.WithValue(ctx, spanKey{}, span)
+		defer span.End()
+	}
+	if c.Meter != nil {
+		c.Meter.AddInFlight(ctx, 1, metricAttrs)
+		defer c.Meter.AddInFlight(ctx, -1, metricAttrs)
+	}
+
+	start := time.Now()
+	response, err := next(ctx, request)
+	duration := time.Since(start)
+
+	result := make(map[string]interface{}, 2)
+	if response != nil {
+		result[AttrHTTPStatusCode] = response.StatusCode
+		if response.StatusCode >= 400 {
+			result[AttrErrorType] = strconv.Itoa(response.StatusCode)
+		}
+	}
+	if err != nil {
+		result[AttrErrorType] = errorType(err)
+	}
+	if span != nil {
+		span.SetAttributes(result)
+		if err != nil {
+			span.RecordError(err)
+		}
+	}
+	if c.Meter != nil {
+		attrs := make(map[string]interface{}, len(metricAttrs)+len(result))
+		for k, v := range metricAttrs {
+			attrs[k] = v
+		}
+		for k, v := range result {
+			attrs[k] = v
+		}
+		c.Meter.RecordDuration(ctx, duration, attrs)
+	}
+	return response, err
+}
+
+// recordResend adds a resend event to the client span in ctx, if any,
+// describing why the previous attempt is being resent.
+func recordResend(ctx context.Context, resends int, res *http.Response, err error) {
+	span := spanFromContext(ctx)
+	if span == nil {
+		return
+	}
+	attrs := map[string]interface{}{AttrHTTPResendCount: resends}
+	if res != nil {
+		attrs[AttrHTTPStatusCode] = res.StatusCode
+	}
+	if err != nil {
+		attrs[AttrErrorType] = errorType(err)
+	}
+	span.AddEvent("resend", attrs)
+}
+
+// routeOf returns the path template of a request with PathParams, such as
+// "/v3/templates/{template_id}", or "" if its path is not templated.
+func routeOf(request Request) string {
+	if request.PathParams == nil {
+		return ""
+	}
+	route := request.BaseURL
+	if i := strings.Index(route, "://"); i >= 0 {
+		route = route[i+3:]
+		if j := strings.Index(route, "/"); j >= 0 {
+			route = route[j:]
+		} else {
+			route = "/"
+		}
+	}
+	if i := strings.IndexAny(route, "?#"); i >= 0 {
+		route = route[:i]
+	}
+	return route
+}
+
+// redactUserinfo returns u as a string with any password removed.
+func redactUserinfo(u *url.URL) string {
+	if _, ok := u.User.Password(); ok {
+		redacted := *u
+		redacted.User = url.UserPassword(u.User.Username(), "REDACTED")
+		return redacted.String()
+	}
+	return u.String()
+}
+
+// errorType returns a low-cardinality description of err.
+func errorType(err error) string {
+	var restErr *RestError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &restErr) && restErr.StatusCode != 0:
+		return strconv.Itoa(restErr.StatusCode)
+	}
+	return fmt.Sprintf("%T", err)
+}
@@ -0,0 +1,162 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+type fakeSpan struct {
+	mu     sync.Mutex
+	name   string
+	attrs  map[string]interface{}
+	events []string
+	err    error
+	ended  bool
+}
+
+func (s *fakeSpan) SetAttributes(attrs map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, v := range attrs {
+		s.attrs[k] = v
+	}
+}
+
+func (s *fakeSpan) AddEvent(name string, attrs map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, name)
+}
+
+func (s *fakeSpan) RecordError(err error) { s.err = err }
+func (s *fakeSpan) End()                  { s.ended = true }
+func (s *fakeSpan) TraceParent() string {
+	return "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+}
+
+type fakeTracer struct {
+	spans []*fakeSpan
+}
+
+func (t *fakeTracer) Start(ctx context.Context, name string, attrs map[string]interface{}) (context.Context, Span) {
+	span := &fakeSpan{name: name, attrs: attrs}
+	t.spans = append(t.spans, span)
+	return ctx, span
+}
+
+type fakeMeter struct {
+	inFlight  int64
+	durations []map[string]interface{}
+}
+
+func (m *fakeMeter) RecordDuration(ctx context.Context, d time.Duration, attrs map[string]interface{}) {
+	m.durations = append(m.durations, attrs)
+}
+
+func (m *fakeMeter) AddInFlight(ctx context.Context, delta int64, attrs map[string]interface{}) {
+	m.inFlight += delta
+}
+
+func TestTracing(t *testing.T) {
+	t.Parallel()
+	var calls int
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("Traceparent") == "" {
+			t.Error("traceparent header was not sent")
+		}
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer fakeServer.Close()
+
+	tracer := &fakeTracer{}
+	meter := &fakeMeter{}
+	client := &Client{
+		HTTPClient:  &http.Client{},
+		RetryPolicy: &RetryPolicy{MaxAttempts: 2, RetryableStatusCodes: []int{http.StatusServiceUnavailable}},
+		Tracer:      tracer,
+		Meter:       meter,
+	}
+	_, err := client.Send(Request{
+		Method:     Get,
+		BaseURL:    fakeServer.URL + "/v3/templates/{template_id}",
+		PathParams: map[string]string{"template_id": "d-1"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(tracer.spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(tracer.spans))
+	}
+	span := tracer.spans[0]
+	if span.name != "GET /v3/templates/{template_id}" {
+		t.Errorf("Wrong span name: %s", span.name)
+	}
+	if span.attrs[AttrURLFull] != fakeServer.URL+"/v3/templates/d-1" {
+		t.Errorf("Wrong url.full: %v", span.attrs[AttrURLFull])
+	}
+	if span.attrs[AttrHTTPStatusCode] != 200 {
+		t.Errorf("Wrong status code: %v", span.attrs[AttrHTTPStatusCode])
+	}
+	if len(span.events) != 1 || span.events[0] != "resend" {
+		t.Errorf("Expected a resend event, got %v", span.events)
+	}
+	if !span.ended {
+		t.Error("Span was not ended")
+	}
+
+	if meter.inFlight != 0 {
+		t.Errorf("In-flight count was not restored: %d", meter.inFlight)
+	}
+	if len(meter.durations) != 1 {
+		t.Fatalf("Expected 1 duration, got %d", len(meter.durations))
+	}
+	attrs := meter.durations[0]
+	if attrs[AttrHTTPRoute] != "/v3/templates/{template_id}" || attrs[AttrHTTPMethod] != "GET" {
+		t.Errorf("Wrong metric attributes: %v", attrs)
+	}
+	if _, ok := attrs[AttrURLFull]; ok {
+		t.Error("Metric attributes should not include the full URL")
+	}
+}
+
+func TestTracingError(t *testing.T) {
+	t.Parallel()
+	tracer := &fakeTracer{}
+	client := &Client{HTTPClient: &http.Client{}, Tracer: tracer}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.SendWithContext(ctx, Request{Method: Get, BaseURL: "http://example.com"}); err == nil {
+		t.Fatal("Expected an error")
+	}
+	span := tracer.spans[0]
+	if span.err == nil || span.attrs[AttrErrorType] != "canceled" {
+		t.Errorf("Error was not recorded: %v, %v", span.err, span.attrs[AttrErrorType])
+	}
+}
+
+func TestRouteOf(t *testing.T) {
+	t.Parallel()
+	tests := map[string]string{
+		"https://api.example.com/v3/{id}?limit=1": "/v3/{id}",
+		"https://api.example.com":                 "/",
+		"/v3/{id}":                                "/v3/{id}",
+	}
+	for baseURL, expected := range tests {
+		route := routeOf(Request{BaseURL: baseURL, PathParams: map[string]string{}})
+		if route != expected {
+			t.Errorf("routeOf(%q) = %q, want %q", baseURL, route, expected)
+		}
+	}
+	if route := routeOf(Request{BaseURL: "https://api.example.com/v3/1"}); route != "" {
+		t.Errorf("Untemplated request has route %q", route)
+	}
+}
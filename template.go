@@ -0,0 +1,55 @@
+package rest
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ExpandPath expands the variables of a URI template, e.g.
+// "/v3/templates/{template_id}/versions/{version_id}", with params.
+//
+// A {name} variable is escaped as a single path segment, so a value
+// containing "/" stays within its segment. A {+name} variable keeps
+// reserved characters such as "/" unescaped. A variable missing from
+// params is an error.
+func ExpandPath(template string, params map[string]string) (string, error) {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			b.WriteString(template)
+			return b.String(), nil
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("rest: unclosed variable in path template %q", template)
+		}
+		end += start
+
+		name, reserved := template[start+1:end], false
+		if strings.HasPrefix(name, "+") {
+			name, reserved = name[1:], true
+		}
+		value, ok := params[name]
+		if !ok {
+			return "", fmt.Errorf("rest: missing path parameter %q", name)
+		}
+		b.WriteString(template[:start])
+		if reserved {
+			b.WriteString(escapeReserved(value))
+		} else {
+			b.WriteString(url.PathEscape(value))
+		}
+		template = template[end+1:]
+	}
+}
+
+// escapeReserved escapes each segment of value, keeping its slashes.
+func escapeReserved(value string) string {
+	segments := strings.Split(value, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,63 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExpandPath(t *testing.T) {
+	t.Parallel()
+	params := map[string]string{
+		"template_id": "d-123",
+		"version_id":  "a/b c",
+		"name":        "café",
+		"path":        "exports/2020 q1.csv",
+	}
+	tests := map[string]string{
+		"/v3/templates/{template_id}/versions/{version_id}": "/v3/templates/d-123/versions/a%2Fb%20c",
+		"/v3/users/{name}":  "/v3/users/caf%C3%A9",
+		"/v3/files/{+path}": "/v3/files/exports/2020%20q1.csv",
+		"/v3/api_keys":      "/v3/api_keys",
+	}
+	for template, expected := range tests {
+		got, err := ExpandPath(template, params)
+		if err != nil {
+			t.Errorf("ExpandPath(%q) returned error: %v", template, err)
+		} else if got != expected {
+			t.Errorf("ExpandPath(%q) = %q, want %q", template, got, expected)
+		}
+	}
+
+	if _, err := ExpandPath("/v3/templates/{missing}", params); err == nil {
+		t.Error("Expected an error for a missing path parameter")
+	}
+	if _, err := ExpandPath("/v3/templates/{template_id", params); err == nil {
+		t.Error("Expected an error for an unclosed variable")
+	}
+}
+
+func TestPathParams(t *testing.T) {
+	t.Parallel()
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/v3/templates/a%2Fb/versions/1" {
+			t.Errorf("Invalid request path: %s", r.URL.EscapedPath())
+		}
+	}))
+	defer fakeServer.Close()
+
+	request := Request{
+		Method:      Get,
+		BaseURL:     fakeServer.URL + "/v3/templates/{template_id}/versions/{version_id}",
+		PathParams:  map[string]string{"template_id": "a/b", "version_id": "1"},
+		QueryParams: map[string]string{"limit": "10"},
+	}
+	if _, err := Send(request); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	request.PathParams = map[string]string{"template_id": "a/b"}
+	if _, err := BuildRequestObject(request); err == nil {
+		t.Error("Expected an error for a missing path parameter")
+	}
+}
@@ -0,0 +1,217 @@
+// Package webhook verifies the signatures of inbound webhook requests, such
+// as SendGrid Event Webhook callbacks.
+package webhook
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers sent with SendGrid Event Webhook requests.
+const (
+	SendGridSignatureHeader = "X-Twilio-Email-Event-Webhook-Signature"
+	SendGridTimestampHeader = "X-Twilio-Email-Event-Webhook-Timestamp"
+)
+
+// DefaultTolerance is how far a request's timestamp may be from the current
+// time when a verifier's Tolerance is zero.
+const DefaultTolerance = 5 * time.Minute
+
+// maxBodySize caps the request bodies read by Handler.
+const maxBodySize = 10 << 20
+
+// Errors returned by verifiers.
+var (
+	ErrMissingSignature = errors.New("webhook: missing signature")
+	ErrInvalidSignature = errors.New("webhook: invalid signature")
+	ErrInvalidTimestamp = errors.New("webhook: invalid timestamp")
+	ErrTimestampExpired = errors.New("webhook: timestamp outside tolerance")
+)
+
+// Verifier verifies the signature of an inbound webhook request.
+type Verifier interface {
+	Verify(header http.Header, body []byte) error
+}
+
+// ECDSAVerifier verifies ECDSA signatures over the SHA-256 of the request
+// timestamp followed by the body, as sent by the SendGrid Event Webhook.
+type ECDSAVerifier struct {
+	PublicKey *ecdsa.PublicKey
+	// SignatureHeader holds the base64 ASN.1 signature.
+	// Defaults to SendGridSignatureHeader.
+	SignatureHeader string
+	// TimestampHeader holds the Unix timestamp.
+	// Defaults to SendGridTimestampHeader.
+	TimestampHeader string
+	// Tolerance is the maximum age of a request. Zero uses
+	// DefaultTolerance and a negative value disables the check.
+	Tolerance time.Duration
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// NewSendGridVerifier returns a verifier for the SendGrid Event Webhook
+// using the base64 verification key shown in the SendGrid settings.
+func NewSendGridVerifier(publicKey string) (*ECDSAVerifier, error) {
+	der, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+	ecdsaKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("webhook: public key is not an ECDSA key")
+	}
+	return &ECDSAVerifier{PublicKey: ecdsaKey}, nil
+}
+
+// Verify checks the signature and timestamp of a request.
+func (v *ECDSAVerifier) Verify(header http.Header, body []byte) error {
+	signature := header.Get(headerOrDefault(v.SignatureHeader, SendGridSignatureHeader))
+	timestamp := header.Get(headerOrDefault(v.TimestampHeader, SendGridTimestampHeader))
+	if signature == "" || timestamp == "" {
+		return ErrMissingSignature
+	}
+	if err := checkTimestamp(timestamp, v.Tolerance, v.Now); err != nil {
+		return err
+	}
+
+	der, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	var sig struct{ R, S *big.Int }
+	if rest, err := asn1.Unmarshal(der, &sig); err != nil || len(rest) != 0 {
+		return ErrInvalidSignature
+	}
+	digest := sha256.Sum256(append([]byte(timestamp), body...))
+	if !ecdsa.Verify(v.PublicKey, digest[:], sig.R, sig.S) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// HMACVerifier verifies HMAC-SHA256 signatures.
+type HMACVerifier struct {
+	Secret []byte
+	// SignatureHeader holds the signature, e.g. X-Signature.
+	SignatureHeader string
+	// SignaturePrefix is stripped from the signature, e.g. "sha256=".
+	SignaturePrefix string
+	// Base64 decodes the signature as base64 rather than hex.
+	Base64 bool
+	// TimestampHeader, when set, holds a Unix timestamp that is checked
+	// against Tolerance and signed along with the body.
+	TimestampHeader string
+	// Payload builds the signed bytes. Defaults to the timestamp followed
+	// by the body.
+	Payload func(timestamp string, body []byte) []byte
+	// Tolerance is the maximum age of a request. Zero uses
+	// DefaultTolerance and a negative value disables the check.
+	Tolerance time.Duration
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Verify checks the signature, and timestamp if configured, of a request.
+func (v *HMACVerifier) Verify(header http.Header, body []byte) error {
+	signature := strings.TrimPrefix(header.Get(v.SignatureHeader), v.SignaturePrefix)
+	if signature == "" {
+		return ErrMissingSignature
+	}
+	var timestamp string
+	if v.TimestampHeader != "" {
+		timestamp = header.Get(v.TimestampHeader)
+		if timestamp == "" {
+			return ErrMissingSignature
+		}
+		if err := checkTimestamp(timestamp, v.Tolerance, v.Now); err != nil {
+			return err
+		}
+	}
+
+	var got []byte
+	var err error
+	if v.Base64 {
+		got, err = base64.StdEncoding.DecodeString(signature)
+	} else {
+		got, err = hex.DecodeString(signature)
+	}
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	payload := append([]byte(timestamp), body...)
+	if v.Payload != nil {
+		payload = v.Payload(timestamp, body)
+	}
+	mac := hmac.New(sha256.New, v.Secret)
+	mac.Write(payload) // nolint
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Handler returns an http.Handler that verifies every request with v
+// before passing it to next. Requests that fail verification get a 401.
+func Handler(v Verifier, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+		if err != nil {
+			http.Error(w, "webhook: cannot read body", http.StatusBadRequest)
+			return
+		}
+		if err := v.Verify(r.Header, body); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkTimestamp parses a Unix timestamp and checks it is within tolerance.
+func checkTimestamp(timestamp string, tolerance time.Duration, now func() time.Time) error {
+	secs, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+	if tolerance < 0 {
+		return nil
+	}
+	if tolerance == 0 {
+		tolerance = DefaultTolerance
+	}
+	if now == nil {
+		now = time.Now
+	}
+	age := now().Sub(time.Unix(secs, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrTimestampExpired
+	}
+	return nil
+}
+
+func headerOrDefault(header, fallback string) string {
+	if header == "" {
+		return fallback
+	}
+	return header
+}
@@ -0,0 +1,185 @@
+package webhook
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPayload = `[{"email":"example@test.com","event":"processed","timestamp":1600112492}]`
+
+func signECDSA(t *testing.T, key *ecdsa.PrivateKey, timestamp, body string) string {
+	digest := sha256.Sum256([]byte(timestamp + body))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+func newSendGridVerifier(t *testing.T) (*ECDSAVerifier, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewSendGridVerifier(base64.StdEncoding.EncodeToString(der))
+	if err != nil {
+		t.Fatalf("Failed to parse public key: %v", err)
+	}
+	return verifier, key
+}
+
+func TestECDSAVerifier(t *testing.T) {
+	t.Parallel()
+	verifier, key := newSendGridVerifier(t)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	header := http.Header{}
+	header.Set(SendGridTimestampHeader, timestamp)
+	header.Set(SendGridSignatureHeader, signECDSA(t, key, timestamp, testPayload))
+	if err := verifier.Verify(header, []byte(testPayload)); err != nil {
+		t.Errorf("Valid signature was rejected: %v", err)
+	}
+	if err := verifier.Verify(header, []byte(testPayload+" ")); err != ErrInvalidSignature {
+		t.Errorf("Expected ErrInvalidSignature for a modified body, got %v", err)
+	}
+
+	header.Set(SendGridSignatureHeader, "bm90IGEgc2lnbmF0dXJl")
+	if err := verifier.Verify(header, []byte(testPayload)); err != ErrInvalidSignature {
+		t.Errorf("Expected ErrInvalidSignature for a malformed signature, got %v", err)
+	}
+	if err := verifier.Verify(http.Header{}, []byte(testPayload)); err != ErrMissingSignature {
+		t.Errorf("Expected ErrMissingSignature, got %v", err)
+	}
+}
+
+func TestECDSAVerifierReplay(t *testing.T) {
+	t.Parallel()
+	verifier, key := newSendGridVerifier(t)
+	timestamp := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+
+	header := http.Header{}
+	header.Set(SendGridTimestampHeader, timestamp)
+	header.Set(SendGridSignatureHeader, signECDSA(t, key, timestamp, testPayload))
+	if err := verifier.Verify(header, []byte(testPayload)); err != ErrTimestampExpired {
+		t.Errorf("Expected ErrTimestampExpired for an old request, got %v", err)
+	}
+
+	verifier.Tolerance = time.Hour
+	if err := verifier.Verify(header, []byte(testPayload)); err != nil {
+		t.Errorf("Request within tolerance was rejected: %v", err)
+	}
+}
+
+func TestNewSendGridVerifierInvalidKey(t *testing.T) {
+	t.Parallel()
+	if _, err := NewSendGridVerifier("not base64!"); err == nil {
+		t.Error("Expected an error for an invalid key")
+	}
+	if _, err := NewSendGridVerifier(base64.StdEncoding.EncodeToString([]byte("not a key"))); err == nil {
+		t.Error("Expected an error for an invalid key")
+	}
+}
+
+func TestHMACVerifier(t *testing.T) {
+	t.Parallel()
+	secret := []byte("secret")
+	now := time.Unix(1600112492, 0)
+	verifier := &HMACVerifier{
+		Secret:          secret,
+		SignatureHeader: "X-Signature",
+		SignaturePrefix: "sha256=",
+		TimestampHeader: "X-Timestamp",
+		Payload: func(timestamp string, body []byte) []byte {
+			return []byte(timestamp + "." + string(body))
+		},
+		Now: func() time.Time { return now },
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("1600112492." + testPayload))
+	header := http.Header{}
+	header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	header.Set("X-Timestamp", "1600112492")
+	if err := verifier.Verify(header, []byte(testPayload)); err != nil {
+		t.Errorf("Valid signature was rejected: %v", err)
+	}
+	if err := verifier.Verify(header, []byte("tampered")); err != ErrInvalidSignature {
+		t.Errorf("Expected ErrInvalidSignature, got %v", err)
+	}
+
+	now = now.Add(time.Hour)
+	if err := verifier.Verify(header, []byte(testPayload)); err != ErrTimestampExpired {
+		t.Errorf("Expected ErrTimestampExpired, got %v", err)
+	}
+
+	header.Set("X-Timestamp", "yesterday")
+	if err := verifier.Verify(header, []byte(testPayload)); err != ErrInvalidTimestamp {
+		t.Errorf("Expected ErrInvalidTimestamp, got %v", err)
+	}
+}
+
+func TestHMACVerifierBase64BodyOnly(t *testing.T) {
+	t.Parallel()
+	secret := []byte("secret")
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(testPayload))
+	header := http.Header{}
+	header.Set("X-Hub-Signature", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+
+	verifier := &HMACVerifier{Secret: secret, SignatureHeader: "X-Hub-Signature", Base64: true}
+	if err := verifier.Verify(header, []byte(testPayload)); err != nil {
+		t.Errorf("Valid signature was rejected: %v", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+	verifier, key := newSendGridVerifier(t)
+	handler := Handler(verifier, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != testPayload {
+			t.Errorf("Body was not restored for the next handler: %q", body)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req := httptest.NewRequest("POST", "/webhook", strings.NewReader(testPayload))
+	req.Header.Set(SendGridTimestampHeader, timestamp)
+	req.Header.Set(SendGridSignatureHeader, signECDSA(t, key, timestamp, testPayload))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("Valid request was rejected with %d", rec.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/webhook", strings.NewReader(testPayload))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Unsigned request got %d, want 401", rec.Code)
+	}
+}